@@ -29,6 +29,8 @@ type Cache struct {
 	sm          *sync.RWMutex
 
 	outdated func(interface{}) bool
+	interval time.Duration
+	swept    time.Time
 }
 
 // NewCache returns a Cache for Elements. The Cache automatically deletes
@@ -44,14 +46,34 @@ func NewCache(outdated func(interface{}) bool) *Cache {
 	}
 }
 
+// NewSweepingCache returns a Cache like NewCache, but outdated Elements are
+// deleted at most once per interval instead of on every update. Use it for
+// caches holding many Elements, where scanning all of them on each update is
+// expensive. Get and Elements may return outdated Elements until the next
+// sweep.
+func NewSweepingCache(outdated func(interface{}) bool, interval time.Duration) *Cache {
+	c := NewCache(outdated)
+	c.interval = interval
+	return c
+}
+
 func (c *Cache) Update(e Element) {
 
 	c.cm.Lock()
 	c.cache[e.Hash()] = e
+	now := time.Now()
+	sweep := now.Sub(c.swept) >= c.interval
+	if sweep {
+		c.swept = now
+	}
 	c.cm.Unlock()
 
 	go c.notify(e)
 
+	if !sweep {
+		return
+	}
+
 	c.cm.RLock()
 	for h, v := range c.cache {
 		if c.outdated(v.Time()) {
@@ -72,11 +94,23 @@ func (c *Cache) Get(hash interface{}) Element {
 	return c.cache[hash]
 }
 
+// Elements returns all cached Elements.
+func (c *Cache) Elements() []Element {
+	c.cm.RLock()
+	defer c.cm.RUnlock()
+	elements := make([]Element, 0, len(c.cache))
+	for _, e := range c.cache {
+		elements = append(elements, e)
+	}
+	return elements
+}
+
 // Subscribe registers a callback to be called each time, when new input is
 // cached and right after calling this function with the currently cached value.
 // If there are observedHashes or observers given, the callback is only called,
-// if the update is related to one of those hashes. It returns the id
-// required for unsubscribing. It returns -1, if callback is nil.
+// if the update is related to one of those hashes. Otherwise it is called for
+// all updates. It returns the id required for unsubscribing. It returns -1, if
+// callback is nil.
 func (c *Cache) Subscribe(callback func(Element), observedHashes []interface{}, observers []func(interface{}) bool) int64 {
 	if callback == nil {
 		return -1
@@ -129,8 +163,9 @@ func (c *Cache) notify(e Element, subs ...*subscriber) {
 	if len(subs) == 0 {
 		c.sm.RLock()
 		for id, s := range c.subscribers {
-			outdated := len(s.observers) == 0
-			for i := len(s.observedHashes); i >= 0; i-- {
+			// subscribers without filters observe everything
+			outdated := len(s.observers) == 0 && len(s.observedHashes) > 0
+			for i := len(s.observedHashes) - 1; i >= 0; i-- {
 				if !c.outdated(s.observedHashes[i]) {
 					outdated = false
 					break
@@ -142,6 +177,7 @@ func (c *Cache) notify(e Element, subs ...*subscriber) {
 				c.sm.RUnlock()
 				c.Unsubscribe(id)
 				c.sm.RLock()
+				continue
 			}
 
 			subs = append(subs, s)
@@ -154,6 +190,10 @@ func (c *Cache) notify(e Element, subs ...*subscriber) {
 
 outer:
 	for _, s := range subs {
+		if len(s.observedHashes) == 0 && len(s.observers) == 0 {
+			go s.callback(e)
+			continue
+		}
 		for _, a := range s.observedHashes {
 			if a == hash {
 				go s.callback(e)
@@ -0,0 +1,97 @@
+package generic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type element struct {
+	t    time.Time
+	hash string
+}
+
+func (e element) Time() time.Time {
+	return e.t
+}
+
+func (e element) Hash() interface{} {
+	return e.hash
+}
+
+func never(interface{}) bool {
+	return false
+}
+
+// collect returns the distinct hashes received within a short period. The
+// initial notification with the cached values may overlap with updates, thus
+// duplicates are possible.
+func collect(c <-chan Element) map[interface{}]bool {
+	hashes := make(map[interface{}]bool)
+	timeout := time.After(100 * time.Millisecond)
+	for {
+		select {
+		case e := <-c:
+			hashes[e.Hash()] = true
+		case <-timeout:
+			return hashes
+		}
+	}
+}
+
+func TestUnfilteredSubscriberReceivesAllUpdates(t *testing.T) {
+	c := NewCache(never)
+	received := make(chan Element, 8)
+	c.Subscribe(func(e Element) { received <- e }, nil, nil)
+
+	c.Update(element{time.Unix(0, 0), "a"})
+	c.Update(element{time.Unix(0, 0), "b"})
+
+	assert.Equal(t, map[interface{}]bool{"a": true, "b": true}, collect(received))
+}
+
+func TestFilteredSubscriberReceivesObservedUpdates(t *testing.T) {
+	c := NewCache(never)
+	received := make(chan Element, 8)
+	c.Subscribe(func(e Element) { received <- e }, []interface{}{"a"}, nil)
+	c.Subscribe(func(e Element) { received <- e }, nil, []func(interface{}) bool{
+		func(hash interface{}) bool { return hash == "c" },
+	})
+
+	c.Update(element{time.Unix(0, 0), "a"})
+	c.Update(element{time.Unix(0, 0), "b"})
+	c.Update(element{time.Unix(0, 0), "c"})
+
+	assert.Equal(t, map[interface{}]bool{"a": true, "c": true}, collect(received))
+}
+
+func TestOutdatedSubscriberIsRemoved(t *testing.T) {
+	c := NewCache(func(hash interface{}) bool {
+		return hash == "old"
+	})
+	c.Subscribe(func(Element) {}, []interface{}{"old"}, nil)
+
+	c.notify(element{time.Unix(0, 0), "new"})
+
+	c.sm.RLock()
+	defer c.sm.RUnlock()
+	assert.Empty(t, c.subscribers)
+}
+
+func TestSweepingCacheDeletesOutdatedOncePerInterval(t *testing.T) {
+	c := NewSweepingCache(func(at interface{}) bool {
+		return at == time.Unix(0, 0)
+	}, time.Hour)
+
+	c.Update(element{time.Unix(1, 0), "a"})
+	c.Update(element{time.Unix(0, 0), "b"})
+	assert.Len(t, c.Elements(), 2)
+
+	c.cm.Lock()
+	c.swept = time.Time{}
+	c.cm.Unlock()
+	c.Update(element{time.Unix(1, 0), "c"})
+	assert.Len(t, c.Elements(), 2)
+	assert.Nil(t, c.Get("b"))
+}
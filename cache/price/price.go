@@ -0,0 +1,88 @@
+package price
+
+import (
+	"time"
+
+	"github.com/theMomax/openefs/cache/generic"
+	cache "github.com/theMomax/openefs/cache/production"
+	"github.com/theMomax/openefs/config"
+	models "github.com/theMomax/openefs/models/production"
+	timeutils "github.com/theMomax/openefs/utils/time"
+)
+
+func init() {
+	config.OnInitialize(func() {
+		step := config.Viper.GetDuration(models.PathStepSize)
+		outdatedAfter = config.Viper.GetDuration(cache.PathRetention)
+		if outdatedAfter < step {
+			outdatedAfter = step
+		}
+		prices = generic.NewSweepingCache(outdated, step)
+	})
+}
+
+// Data holds the electricity-prices valid during a single time-step. Prices
+// are given per kWh and may be negative.
+type Data struct {
+	// Import is the price for buying energy from the grid.
+	Import float64 `csv:"import" json:"import"`
+	// Export is the revenue for feeding energy into the grid.
+	Export float64 `csv:"export" json:"export"`
+}
+
+type element struct {
+	data Data
+	time time.Time
+}
+
+var outdatedAfter time.Duration
+
+var prices *generic.Cache
+
+func outdated(at interface{}) bool {
+	t, ok := at.(time.Time)
+	return !ok || timeutils.Since(t) >= outdatedAfter
+}
+
+func (e *element) Time() time.Time {
+	return e.time
+}
+
+func (e *element) Hash() interface{} {
+	return models.Round(e.time)
+}
+
+// Update stores the prices valid during the step at time t. Prices already
+// known for that step are replaced.
+func Update(t time.Time, d Data) {
+	prices.Update(&element{
+		data: d,
+		time: t,
+	})
+}
+
+// Get returns the prices valid during the step at time t or nil if they are
+// unknown.
+func Get(t time.Time) *Data {
+	v, ok := prices.Get(models.Round(t)).(*element)
+	if !ok {
+		return nil
+	}
+	d := v.data
+	return &d
+}
+
+// Subscribe registers a callback to be called each time, when new prices are
+// stored. It returns the id required for unsubscribing.
+func Subscribe(callback func(t time.Time, d Data)) int64 {
+	return prices.Subscribe(func(e generic.Element) {
+		if v, ok := e.(*element); ok {
+			callback(v.time, v.data)
+		}
+	}, nil, nil)
+}
+
+// Unsubscribe the callback with the given id.
+func Unsubscribe(id int64) {
+	prices.Unsubscribe(id)
+}
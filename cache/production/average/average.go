@@ -32,7 +32,15 @@ type element struct {
 	nonderived *numbers.Average
 	m          *sync.Mutex
 	daysAhead  uint
-	hourOfDay  uint
+	stepOfDay  uint
+	// array is empty for elements describing the whole site
+	array string
+}
+
+// key is the hash of an element.
+type key struct {
+	array string
+	step  uint
 }
 
 var halfLife float64
@@ -43,30 +51,34 @@ var cache *generic.Cache
 
 // Run initializes the caching package.
 func Run() {
-	models.Subscribe(func(u models.Update) {
-		dist := models.Round(u.Time()).Sub(models.Round(timeutils.Now()))
-		daysAhead := uint(dist.Truncate(24*time.Hour) / (24 * time.Hour))
-		hourOfDay := uint(u.Time().Hour())
-
-		v, ok := cache.Get(daysAhead*24 + hourOfDay).(*element)
-		if !ok {
-			v = &element{
-				derived:    numbers.NewAverageSum(halfLife),
-				nonderived: numbers.NewAverageSum(halfLife),
-				m:          &sync.Mutex{},
-				daysAhead:  daysAhead,
-				hourOfDay:  hourOfDay,
-			}
-		}
-		v.m.Lock()
-		defer v.m.Unlock()
-		if u.IsDerived() {
-			v.derived.Apply(u.Data().Power)
-		} else {
-			v.nonderived.Apply(u.Data().Power)
+	models.Subscribe(record)
+	models.SubscribeArrays(record)
+}
+
+func record(u models.Update) {
+	daysAhead := models.DaysAhead(u.Time())
+	stepOfDay := models.StepOfDay(u.Time())
+	array := u.Data().Array
+
+	v, ok := cache.Get(hash(array, daysAhead, stepOfDay)).(*element)
+	if !ok {
+		v = &element{
+			derived:    numbers.NewAverageSum(halfLife),
+			nonderived: numbers.NewAverageSum(halfLife),
+			m:          &sync.Mutex{},
+			daysAhead:  daysAhead,
+			stepOfDay:  stepOfDay,
+			array:      array,
 		}
-		cache.Update(v)
-	})
+	}
+	v.m.Lock()
+	defer v.m.Unlock()
+	if u.IsDerived() {
+		v.derived.Apply(u.Data().Power)
+	} else {
+		v.nonderived.Apply(u.Data().Power)
+	}
+	cache.Update(v)
 }
 
 func outdated(at interface{}) bool {
@@ -74,16 +86,27 @@ func outdated(at interface{}) bool {
 }
 
 func (e *element) Time() time.Time {
-	return timeutils.Now().Add(time.Duration(e.daysAhead) * 24 * time.Hour).Add(time.Duration(e.hourOfDay) * time.Hour)
+	return timeutils.Now().Add(time.Duration(e.daysAhead) * 24 * time.Hour).Add(time.Duration(e.stepOfDay) * config.Viper.GetDuration(models.PathStepSize))
 }
 
 func (e *element) Hash() interface{} {
-	return e.daysAhead*24 + e.hourOfDay
+	return hash(e.array, e.daysAhead, e.stepOfDay)
+}
+
+func hash(array string, daysAhead, stepOfDay uint) key {
+	return key{array, daysAhead*models.StepsPerDay() + stepOfDay}
 }
 
-// GetDerived returns the average derived power for time t.
-func GetDerived(daysAhead, hourOfDay uint) (val float64, ok bool) {
-	v, ok := cache.Get(daysAhead*24 + hourOfDay).(*element)
+// GetDerived returns the average derived power for the given step of the day
+// daysAhead days in the future.
+func GetDerived(daysAhead, stepOfDay uint) (val float64, ok bool) {
+	return GetArrayDerived("", daysAhead, stepOfDay)
+}
+
+// GetArrayDerived returns the average derived power of the given array like
+// GetDerived.
+func GetArrayDerived(array string, daysAhead, stepOfDay uint) (val float64, ok bool) {
+	v, ok := cache.Get(hash(array, daysAhead, stepOfDay)).(*element)
 	if !ok {
 		return 0.0, false
 	}
@@ -92,9 +115,16 @@ func GetDerived(daysAhead, hourOfDay uint) (val float64, ok bool) {
 	return v.derived.Get(), true
 }
 
-// GetNonDerived returns the average non-derived power for time t.
-func GetNonDerived(daysAhead, hourOfDay uint) (val float64, ok bool) {
-	v, ok := cache.Get(daysAhead*24 + hourOfDay).(*element)
+// GetNonDerived returns the average non-derived power for the given step of
+// the day daysAhead days in the future.
+func GetNonDerived(daysAhead, stepOfDay uint) (val float64, ok bool) {
+	return GetArrayNonDerived("", daysAhead, stepOfDay)
+}
+
+// GetArrayNonDerived returns the average non-derived power of the given array
+// like GetNonDerived.
+func GetArrayNonDerived(array string, daysAhead, stepOfDay uint) (val float64, ok bool) {
+	v, ok := cache.Get(hash(array, daysAhead, stepOfDay)).(*element)
 	if !ok {
 		return 0.0, false
 	}
@@ -0,0 +1,63 @@
+package average
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/theMomax/openefs/persistence"
+	"github.com/theMomax/openefs/utils/numbers"
+)
+
+func init() {
+	persistence.Register("cache.production.average", save, load)
+}
+
+// snapshot is the persisted form of an element.
+type snapshot struct {
+	Array      string        `json:"array,omitempty"`
+	DaysAhead  uint          `json:"daysAhead"`
+	StepOfDay  uint          `json:"stepOfDay"`
+	Derived    numbers.State `json:"derived"`
+	NonDerived numbers.State `json:"nonDerived"`
+}
+
+func save() interface{} {
+	snapshots := make([]snapshot, 0)
+	for _, e := range cache.Elements() {
+		v, ok := e.(*element)
+		if !ok {
+			continue
+		}
+		v.m.Lock()
+		snapshots = append(snapshots, snapshot{
+			Array:      v.array,
+			DaysAhead:  v.daysAhead,
+			StepOfDay:  v.stepOfDay,
+			Derived:    v.derived.State(),
+			NonDerived: v.nonderived.State(),
+		})
+		v.m.Unlock()
+	}
+	return snapshots
+}
+
+func load(b []byte) error {
+	var snapshots []snapshot
+	if err := json.Unmarshal(b, &snapshots); err != nil {
+		return err
+	}
+	for _, s := range snapshots {
+		v := &element{
+			derived:    numbers.NewAverageSum(halfLife),
+			nonderived: numbers.NewAverageSum(halfLife),
+			m:          &sync.Mutex{},
+			daysAhead:  s.DaysAhead,
+			stepOfDay:  s.StepOfDay,
+			array:      s.Array,
+		}
+		v.derived.Restore(s.Derived)
+		v.nonderived.Restore(s.NonDerived)
+		cache.Update(v)
+	}
+	return nil
+}
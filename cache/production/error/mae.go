@@ -24,6 +24,7 @@ func init() {
 	config.OnInitialize(func() {
 		halfLife = config.Viper.GetFloat64(PathHalfLife)
 		outdatedAfter = config.Viper.GetDuration(models.PathStepSize)
+		stepSize = config.Viper.GetDuration(models.PathStepSize)
 		cache = generic.NewCache(outdated)
 	})
 
@@ -37,16 +38,27 @@ var log *logrus.Logger
 type element struct {
 	predictions map[time.Duration]models.Data
 	date        time.Time
+	// array is empty for elements describing the whole site
+	array string
+}
+
+// key is the hash of an element.
+type key struct {
+	array string
+	time  time.Time
 }
 
 var (
 	outdatedAfter time.Duration
+	stepSize      time.Duration
 	halfLife      float64
 )
 
 var cache *generic.Cache
 
-var emap = make(map[time.Duration]*numbers.Average)
+// emap holds the errors of each array by lead-time. The site's errors are
+// stored for the empty array-id.
+var emap = make(map[string]map[time.Duration]*numbers.Average)
 var emapm = &sync.RWMutex{}
 
 var completed time.Time
@@ -54,51 +66,62 @@ var completedm = &sync.RWMutex{}
 
 // Run initializes the caching package.
 func Run() {
-	models.Subscribe(func(u models.Update) {
-		// if actual value is not known yet, cache predicted ones
-		if u.IsDerived() {
-			log.WithField("time", u.Time()).WithField("value", u.Data().Power).Trace("errorcache received derived update")
-			e := get(u.Time())
-			log.Trace(e)
-			if e == nil {
-				log.Trace("initialized e")
-				e = &element{
-					predictions: make(map[time.Duration]models.Data),
-					date:        u.Time(),
-				}
+	models.Subscribe(record)
+	models.SubscribeArrays(record)
+}
+
+// record caches derived updates and updates the error, once the actual value
+// is known.
+func record(u models.Update) {
+	array := u.Data().Array
+
+	// if actual value is not known yet, cache predicted ones
+	if u.IsDerived() {
+		log.WithField("time", u.Time()).WithField("value", u.Data().Power).Trace("errorcache received derived update")
+		e := get(array, u.Time())
+		log.Trace(e)
+		if e == nil {
+			log.Trace("initialized e")
+			e = &element{
+				predictions: make(map[time.Duration]models.Data),
+				date:        u.Time(),
+				array:       array,
 			}
+		}
 
-			log.Trace("set prediction at duration ", models.Round(e.date).Sub(models.Round(timeutils.Now())).String())
-			e.predictions[models.Round(e.date).Sub(models.Round(timeutils.Now()))] = *u.Data()
+		d := leadTime(e.date)
+		log.Trace("set prediction at duration ", d.String())
+		e.predictions[d] = *u.Data()
 
-			cache.Update(e)
-			return
-		}
+		cache.Update(e)
+		return
+	}
 
-		log.WithField("time", u.Time()).WithField("value", u.Data().Power).Trace("errorcache received original update")
-		// otherwise calculate error
-		if e := get(u.Time()); e != nil {
-			log.Trace(e)
-			log.Trace(e.predictions)
-			emapm.Lock()
-			defer emapm.Unlock()
-			for d, v := range e.predictions {
-				if emap[d] == nil {
-					log.Trace("initialized MAE for ", d.String(), " ahead")
-					emap[d] = numbers.NewMAE(halfLife)
-				}
-				emap[d].Apply(u.Data().Power, v.Power)
-				log.WithField("value", emap[d].Get()).WithField("duration_ahead", d.String()).Info("updated production-error")
-			}
+	log.WithField("time", u.Time()).WithField("value", u.Data().Power).Trace("errorcache received original update")
+	// otherwise calculate error, unless the actual value is incomplete
+	if e := get(array, u.Time()); e != nil && !models.IsIncomplete(u) {
+		log.Trace(e)
+		log.Trace(e.predictions)
+		emapm.Lock()
+		defer emapm.Unlock()
+		if emap[array] == nil {
+			emap[array] = make(map[time.Duration]*numbers.Average)
 		}
-		completedm.Lock()
-		if completed.Sub(u.Time()) < 0 {
-			log.Trace("updated completed from ", completed.String(), " to ", u.Time().String())
-			completed = u.Time()
+		for d, v := range e.predictions {
+			if emap[array][d] == nil {
+				log.Trace("initialized MAE for ", d.String(), " ahead")
+				emap[array][d] = numbers.NewMAE(halfLife)
+			}
+			emap[array][d].Apply(u.Data().Power, v.Power)
+			log.WithField("value", emap[array][d].Get()).WithField("duration_ahead", d.String()).WithField("array", array).Info("updated production-error")
 		}
-		completedm.Unlock()
-	})
-
+	}
+	completedm.Lock()
+	if completed.Sub(u.Time()) < 0 {
+		log.Trace("updated completed from ", completed.String(), " to ", u.Time().String())
+		completed = u.Time()
+	}
+	completedm.Unlock()
 }
 
 func outdated(at interface{}) bool {
@@ -117,23 +140,35 @@ func (e *element) Time() time.Time {
 }
 
 func (e *element) Hash() interface{} {
-	return models.Round(e.Time())
+	return key{e.array, models.Round(e.Time())}
 }
 
 // MAE returns the production-model's mean absolute error, where d is the
 // duration between realtime and the point in time, where the model predicted
-// the values.
+// the values. d is rounded to a multiple of the step-size.
 func MAE(d time.Duration) (val float64, ok bool) {
+	return ArrayMAE("", d)
+}
+
+// ArrayMAE returns the mean absolute error of the given array's predictions
+// like MAE. The site's error is returned for the empty array-id.
+func ArrayMAE(array string, d time.Duration) (val float64, ok bool) {
 	emapm.RLock()
 	defer emapm.RUnlock()
-	if a := emap[d]; a != nil {
+	if a := emap[array][d.Round(stepSize)]; a != nil {
 		return a.Get(), true
 	}
 	return 0, false
 }
 
-func get(t time.Time) *element {
-	v, ok := cache.Get(models.Round(t)).(*element)
+// leadTime returns the duration between the current step and the step t
+// belongs to. It is always a multiple of the step-size.
+func leadTime(t time.Time) time.Duration {
+	return models.Round(t).Sub(models.Round(timeutils.Now())).Round(stepSize)
+}
+
+func get(array string, t time.Time) *element {
+	v, ok := cache.Get(key{array, models.Round(t)}).(*element)
 	if !ok {
 		return nil
 	}
@@ -0,0 +1,44 @@
+package error
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/theMomax/openefs/persistence"
+	"github.com/theMomax/openefs/utils/numbers"
+)
+
+func init() {
+	persistence.Register("cache.production.error", save, load)
+}
+
+// save returns the errors of each array by lead-time.
+func save() interface{} {
+	emapm.RLock()
+	defer emapm.RUnlock()
+	snapshot := make(map[string]map[time.Duration]numbers.State, len(emap))
+	for array, errors := range emap {
+		snapshot[array] = make(map[time.Duration]numbers.State, len(errors))
+		for d, e := range errors {
+			snapshot[array][d] = e.State()
+		}
+	}
+	return snapshot
+}
+
+func load(b []byte) error {
+	var snapshot map[string]map[time.Duration]numbers.State
+	if err := json.Unmarshal(b, &snapshot); err != nil {
+		return err
+	}
+	emapm.Lock()
+	defer emapm.Unlock()
+	for array, errors := range snapshot {
+		emap[array] = make(map[time.Duration]*numbers.Average, len(errors))
+		for d, s := range errors {
+			emap[array][d] = numbers.NewMAE(halfLife)
+			emap[array][d].Restore(s)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package production
+
+import (
+	"encoding/json"
+	"time"
+
+	models "github.com/theMomax/openefs/models/production"
+	"github.com/theMomax/openefs/persistence"
+	"github.com/theMomax/openefs/utils/metadata"
+	timeutils "github.com/theMomax/openefs/utils/time"
+)
+
+func init() {
+	persistence.Register("cache.production", save, load)
+}
+
+// snapshot is the persisted form of a provided production-value. Derived
+// values are not persisted, as they are outdated by the time they are
+// restored.
+type snapshot struct {
+	Time time.Time   `json:"time"`
+	Data models.Data `json:"data"`
+}
+
+// restored is a provided production-value restored from a snapshot.
+type restored struct {
+	data *models.Data
+	time time.Time
+	meta metadata.Metadata
+}
+
+func (u *restored) Data() *models.Data {
+	return u.data
+}
+
+func (u *restored) Time() time.Time {
+	return u.time
+}
+
+func (u *restored) Meta() metadata.Metadata {
+	return u.meta
+}
+
+func (u *restored) IsDerived() bool {
+	return false
+}
+
+func save() interface{} {
+	snapshots := make([]snapshot, 0)
+	for _, e := range cache.Elements() {
+		v, ok := e.(*element)
+		if !ok || v.u.IsDerived() || v.u.Data() == nil {
+			continue
+		}
+		snapshots = append(snapshots, snapshot{
+			Time: v.u.Time(),
+			Data: *v.u.Data(),
+		})
+	}
+	return snapshots
+}
+
+func load(b []byte) error {
+	var snapshots []snapshot
+	if err := json.Unmarshal(b, &snapshots); err != nil {
+		return err
+	}
+	for i := range snapshots {
+		if outdated(snapshots[i].Time) {
+			continue
+		}
+		cache.Update(&element{&restored{
+			data: &snapshots[i].Data,
+			time: snapshots[i].Time,
+			meta: &metadata.Basic{
+				Timestamp: timeutils.Now(),
+			},
+		}})
+	}
+	return nil
+}
@@ -9,10 +9,24 @@ import (
 	timeutils "github.com/theMomax/openefs/utils/time"
 )
 
+// Config paths
+const (
+	PathRetention = "cache.production.retention"
+)
+
 func init() {
+	config.RootCtx.PersistentFlags().Duration(PathRetention, 31*24*time.Hour, "the duration for which past production-values are kept")
+	config.Viper.BindPFlag(PathRetention, config.RootCtx.PersistentFlags().Lookup(PathRetention))
+
 	config.OnInitialize(func() {
-		outdatedAfter = config.Viper.GetDuration(models.PathStepSize)
-		cache = generic.NewCache(outdated)
+		step := config.Viper.GetDuration(models.PathStepSize)
+		outdatedAfter = config.Viper.GetDuration(PathRetention)
+		if outdatedAfter < step {
+			outdatedAfter = step
+		}
+		// the retention spans many steps, thus outdated values are only
+		// removed once per step
+		cache = generic.NewSweepingCache(outdated, step)
 	})
 }
 
@@ -20,6 +34,12 @@ type element struct {
 	u models.Update
 }
 
+// arrayKey is the hash of an element holding the value of a single array.
+type arrayKey struct {
+	array string
+	time  time.Time
+}
+
 var outdatedAfter time.Duration
 
 var cache *generic.Cache
@@ -27,8 +47,13 @@ var cache *generic.Cache
 // Run initializes the caching package.
 func Run() {
 	models.Subscribe(func(u models.Update) {
-		// don't overwrite non-derived value
-		if prev := Update(u.Time()); prev == nil || prev.IsDerived() {
+		// don't overwrite non-derived value, unless it is incomplete
+		if prev := Update(u.Time()); prev == nil || prev.IsDerived() || models.IsIncomplete(prev) {
+			cache.Update(&element{u})
+		}
+	})
+	models.SubscribeArrays(func(u models.Update) {
+		if prev := ArrayUpdate(u.Data().Array, u.Time()); prev == nil || prev.IsDerived() {
 			cache.Update(&element{u})
 		}
 	})
@@ -44,6 +69,9 @@ func (e *element) Time() time.Time {
 }
 
 func (e *element) Hash() interface{} {
+	if e.u.Data() != nil && e.u.Data().Array != "" {
+		return arrayKey{e.u.Data().Array, models.Round(e.Time())}
+	}
 	return models.Round(e.Time())
 }
 
@@ -56,10 +84,21 @@ func Update(t time.Time) models.Update {
 	return v.u
 }
 
+// ArrayUpdate returns the latest available update of the given array for time
+// t.
+func ArrayUpdate(array string, t time.Time) models.Update {
+	v, ok := cache.Get(arrayKey{array, models.Round(t)}).(*element)
+	if !ok {
+		return nil
+	}
+	return v.u
+}
+
 // Subscribe registers a callback to be called each time, when the underlying
 // model creates new output and immediately with the currently cached value. If
 // there are (relative) timestamps given, the callback is only called, if the
-// update is related to one of those timestamps. It returns the id required for
+// update is related to one of those timestamps. Otherwise, it is called for
+// all updates including those of single arrays. It returns the id required for
 // unsubscribing. It returns -1, if callback is nil.
 func Subscribe(callback func(models.Update), absolute []time.Time, relative []time.Duration) int64 {
 	observedHashes := make([]interface{}, len(absolute))
@@ -80,6 +119,7 @@ func Subscribe(callback func(models.Update), absolute []time.Time, relative []ti
 		v, ok := e.(*element)
 		if !ok {
 			callback(nil)
+			return
 		}
 		callback(v.u)
 	}, observedHashes, observers)
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/theMomax/openefs/config"
+	"github.com/theMomax/openefs/models/production"
+)
+
+// Config paths
+const (
+	PathBacktestData  = "backtest.data"
+	PathBacktestFolds = "backtest.folds"
+)
+
+var backtestCmd = &cobra.Command{
+	Use:   "backtest",
+	Short: "Evaluate the production-model's configuration on historical data",
+	Long:  `Backtest evaluates the configured production-model by walk-forward validation on historical data (backtest.data). For each fold, a fresh model is trained on all preceding data and predicts the following block. The errors are written to stdout as JSON. The production-model is not touched.`,
+	Args:  cobra.NoArgs,
+	Run:   backtest,
+}
+
+func init() {
+	config.RootCtx.PersistentFlags().String(PathBacktestData, "", "the CSV-file holding the historical data for backtests (columns: time, production, optionally curtailed and weather-fields)")
+	config.Viper.BindPFlag(PathBacktestData, config.RootCtx.PersistentFlags().Lookup(PathBacktestData))
+
+	config.RootCtx.PersistentFlags().Uint(PathBacktestFolds, 4, "the amount of folds of a walk-forward backtest")
+	config.Viper.BindPFlag(PathBacktestFolds, config.RootCtx.PersistentFlags().Lookup(PathBacktestFolds))
+
+	config.RootCtx.AddCommand(backtestCmd)
+}
+
+func backtest(cmd *cobra.Command, args []string) {
+	if config.Viper.GetUint(PathBacktestFolds) == 0 {
+		config.InvalidConfiguration(PathBacktestFolds, "[1, +inf)")
+	}
+	f, err := os.Open(config.Viper.GetString(PathBacktestData))
+	if err != nil {
+		log.WithError(err).Fatal("could not open historical data")
+	}
+	records, err := production.ReadRecords(f)
+	f.Close()
+	if err != nil {
+		log.WithError(err).Fatal("could not read historical data")
+	}
+
+	result, err := production.Backtest(records, config.Viper.GetUint(PathBacktestFolds))
+	if err != nil {
+		log.WithError(err).Fatal("backtest failed")
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(result); err != nil {
+		log.WithError(err).Fatal("could not write result")
+	}
+}
@@ -0,0 +1,28 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/theMomax/openefs/config"
+	"github.com/theMomax/openefs/models/production"
+)
+
+var modelCmd = &cobra.Command{
+	Use:   "model",
+	Short: "Manage the production-model",
+}
+
+var modelBuildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Build a fresh production-model",
+	Long:  `Build replaces the production-model by a new, untrained one, that matches the configured features and model-spec (models.production.spec.*). The replaced model is kept as snapshot.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		production.BuildModel()
+		log.Info("built production-model")
+	},
+}
+
+func init() {
+	modelCmd.AddCommand(modelBuildCmd)
+	config.RootCtx.AddCommand(modelCmd)
+}
@@ -1,11 +1,18 @@
 package cli
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/theMomax/openefs/cache"
 	"github.com/theMomax/openefs/config"
 	"github.com/theMomax/openefs/models"
+	"github.com/theMomax/openefs/optimization"
+	"github.com/theMomax/openefs/persistence"
 	"github.com/theMomax/openefs/server"
 )
 
@@ -24,7 +31,41 @@ func Execute() error {
 }
 
 func run(cmd *cobra.Command, args []string) {
-	models.Run()
+	persistence.Load()
+
+	serverCtx, stopServer := context.WithCancel(context.Background())
+	defer stopServer()
+	modelsCtx, stopModels := context.WithCancel(context.Background())
+	defer stopModels()
+	go stopOnSignal(stopServer)
+
+	stopped := models.Run(modelsCtx)
 	cache.Run()
-	log.WithError(server.Run()).Panic("Unexpected panic!")
+	optimization.Run()
+	if err := server.Run(serverCtx); err != nil {
+		log.WithError(err).Panic("Unexpected panic!")
+	}
+
+	// the server does not accept input anymore, thus the models can process
+	// the remaining updates
+	log.Info("waiting for models to complete...")
+	stopModels()
+	<-stopped
+
+	if err := persistence.Save(); err != nil {
+		log.WithError(err).Error("could not save state")
+	}
+	log.Info("shut down")
+}
+
+// stopOnSignal calls stop on the first SIGINT or SIGTERM. The application
+// exits immediately on the second one.
+func stopOnSignal(stop func()) {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	s := <-signals
+	log.WithField("signal", s).Info("shutting down...")
+	stop()
+	s = <-signals
+	log.WithField("signal", s).Fatal("forced shutdown")
 }
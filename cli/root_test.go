@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/theMomax/openefs/config"
+)
+
+// TestInitializesWithClearSkyColdStart runs all configuration-callbacks. An
+// invalid configuration exits the test-binary.
+func TestInitializesWithClearSkyColdStart(t *testing.T) {
+	initialized := false
+	noop := &cobra.Command{
+		Use: "noop",
+		Run: func(cmd *cobra.Command, args []string) {
+			initialized = true
+		},
+	}
+	config.RootCtx.AddCommand(noop)
+	defer config.RootCtx.RemoveCommand(noop)
+
+	config.RootCtx.SetArgs([]string{
+		noop.Name(),
+		"--models.production.forecaster=clearsky",
+		"--models.production.maximumpower=1000",
+		"--models.production.coldstart=clearsky",
+		"--persistence.directory=",
+	})
+	defer config.RootCtx.SetArgs(nil)
+	assert.NoError(t, config.RootCtx.Execute())
+	assert.True(t, initialized)
+}
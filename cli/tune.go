@@ -0,0 +1,325 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/theMomax/openefs/config"
+	"github.com/theMomax/openefs/models/production"
+	"github.com/theMomax/openefs/utils/search"
+)
+
+// Config paths
+const (
+	PathTuneSearch               = "tune.search"
+	PathTuneTrials               = "tune.trials"
+	PathTuneSeed                 = "tune.seed"
+	PathTuneReport               = "tune.report"
+	PathTunePromote              = "tune.promote"
+	PathTuneConsideredSteps      = "tune.consideredsteps"
+	PathTuneNormalizationMethods = "tune.normalizationmethods"
+	PathTuneFeatures             = "tune.features"
+	PathTuneLayers               = "tune.layers"
+	PathTuneUnits                = "tune.units"
+	PathTuneOptimizers           = "tune.optimizers"
+	PathTuneLearningRates        = "tune.learningrates"
+	PathTuneLosses               = "tune.losses"
+	PathTuneEpochs               = "tune.epochs"
+)
+
+// search strategies
+const (
+	grid   = "grid"
+	random = "random"
+)
+
+// tuned maps the search-space's config paths to the model's config paths.
+var tuned = []struct {
+	search string
+	model  string
+}{
+	{PathTuneConsideredSteps, production.PathConsideredSteps},
+	{PathTuneNormalizationMethods, production.PathNormalizationMethod},
+	{PathTuneFeatures, production.PathFeatures},
+	{PathTuneLayers, production.PathSpecLayers},
+	{PathTuneUnits, production.PathSpecUnits},
+	{PathTuneOptimizers, production.PathSpecOptimizer},
+	{PathTuneLearningRates, production.PathSpecLearningRate},
+	{PathTuneLosses, production.PathSpecLoss},
+	{PathTuneEpochs, production.PathSpecEpochs},
+}
+
+var tuneCmd = &cobra.Command{
+	Use:   "tune",
+	Short: "Search the production-model's hyperparameters",
+	Long: `Tune searches the production-model's hyperparameters (tune.*) by grid- or random-search. Each candidate is evaluated by a walk-forward backtest on historical data (backtest.data). Parameters without values to search keep their configured value. The candidates are written to a report (tune.report) ranked by their MAE.
+
+If tune.promote names a config-file, the best candidate's parameters are written to it. Existing settings in the file are kept. Run 'openefs model build' afterwards to create a model matching the promoted configuration.`,
+	Args: cobra.NoArgs,
+}
+
+func init() {
+	config.RootCtx.PersistentFlags().String(PathTuneSearch, grid, "the strategy of the hyperparameter-search (one of: "+grid+", "+random+")")
+	config.Viper.BindPFlag(PathTuneSearch, config.RootCtx.PersistentFlags().Lookup(PathTuneSearch))
+
+	config.RootCtx.PersistentFlags().Uint(PathTuneTrials, 20, "the amount of candidates evaluated by a random hyperparameter-search")
+	config.Viper.BindPFlag(PathTuneTrials, config.RootCtx.PersistentFlags().Lookup(PathTuneTrials))
+
+	config.RootCtx.PersistentFlags().Int64(PathTuneSeed, 0, "the seed of a random hyperparameter-search (0 for a random seed)")
+	config.Viper.BindPFlag(PathTuneSeed, config.RootCtx.PersistentFlags().Lookup(PathTuneSeed))
+
+	config.RootCtx.PersistentFlags().String(PathTuneReport, "tune.json", "the file the ranked report of a hyperparameter-search is written to")
+	config.Viper.BindPFlag(PathTuneReport, config.RootCtx.PersistentFlags().Lookup(PathTuneReport))
+
+	config.RootCtx.PersistentFlags().String(PathTunePromote, "", "the config-file the best candidate of a hyperparameter-search is written to (empty to disable)")
+	config.Viper.BindPFlag(PathTunePromote, config.RootCtx.PersistentFlags().Lookup(PathTunePromote))
+
+	config.RootCtx.PersistentFlags().StringSlice(PathTuneConsideredSteps, nil, "the values of "+production.PathConsideredSteps+" to search")
+	config.Viper.BindPFlag(PathTuneConsideredSteps, config.RootCtx.PersistentFlags().Lookup(PathTuneConsideredSteps))
+
+	config.RootCtx.PersistentFlags().StringSlice(PathTuneNormalizationMethods, nil, "the values of "+production.PathNormalizationMethod+" to search")
+	config.Viper.BindPFlag(PathTuneNormalizationMethods, config.RootCtx.PersistentFlags().Lookup(PathTuneNormalizationMethods))
+
+	config.RootCtx.PersistentFlags().StringArray(PathTuneFeatures, nil, "the values of "+production.PathFeatures+" to search (each a comma-separated list)")
+	config.Viper.BindPFlag(PathTuneFeatures, config.RootCtx.PersistentFlags().Lookup(PathTuneFeatures))
+
+	config.RootCtx.PersistentFlags().StringArray(PathTuneLayers, nil, "the values of "+production.PathSpecLayers+" to search (each a comma-separated list)")
+	config.Viper.BindPFlag(PathTuneLayers, config.RootCtx.PersistentFlags().Lookup(PathTuneLayers))
+
+	config.RootCtx.PersistentFlags().StringSlice(PathTuneUnits, nil, "the values of "+production.PathSpecUnits+" to search")
+	config.Viper.BindPFlag(PathTuneUnits, config.RootCtx.PersistentFlags().Lookup(PathTuneUnits))
+
+	config.RootCtx.PersistentFlags().StringSlice(PathTuneOptimizers, nil, "the values of "+production.PathSpecOptimizer+" to search")
+	config.Viper.BindPFlag(PathTuneOptimizers, config.RootCtx.PersistentFlags().Lookup(PathTuneOptimizers))
+
+	config.RootCtx.PersistentFlags().StringSlice(PathTuneLearningRates, nil, "the values of "+production.PathSpecLearningRate+" to search")
+	config.Viper.BindPFlag(PathTuneLearningRates, config.RootCtx.PersistentFlags().Lookup(PathTuneLearningRates))
+
+	config.RootCtx.PersistentFlags().StringSlice(PathTuneLosses, nil, "the values of "+production.PathSpecLoss+" to search")
+	config.Viper.BindPFlag(PathTuneLosses, config.RootCtx.PersistentFlags().Lookup(PathTuneLosses))
+
+	config.RootCtx.PersistentFlags().StringSlice(PathTuneEpochs, nil, "the values of "+production.PathSpecEpochs+" to search")
+	config.Viper.BindPFlag(PathTuneEpochs, config.RootCtx.PersistentFlags().Lookup(PathTuneEpochs))
+
+	tuneCmd.Run = tune
+	config.RootCtx.AddCommand(tuneCmd)
+}
+
+// trial is the evaluation of a single candidate.
+type trial struct {
+	Rank       int                `json:"rank"`
+	Parameters search.Candidate   `json:"parameters"`
+	Result     *production.Result `json:"result,omitempty"`
+	// Error describes why the candidate could not be evaluated.
+	Error string `json:"error,omitempty"`
+}
+
+func tune(cmd *cobra.Command, args []string) {
+	space := make([]search.Dimension, 0, len(tuned))
+	for _, t := range tuned {
+		if values := searchValues(t.search); len(values) > 0 {
+			space = append(space, search.Dimension{Name: t.model, Values: values})
+		}
+	}
+
+	var candidates []search.Candidate
+	switch config.Viper.GetString(PathTuneSearch) {
+	case grid:
+		candidates = search.Grid(space)
+	case random:
+		if config.Viper.GetUint(PathTuneTrials) == 0 {
+			config.InvalidConfiguration(PathTuneTrials, "[1, +inf)")
+		}
+		seed := config.Viper.GetInt64(PathTuneSeed)
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		candidates = search.Random(space, int(config.Viper.GetUint(PathTuneTrials)), rand.New(rand.NewSource(seed)))
+	default:
+		config.InvalidConfiguration(PathTuneSearch, grid+", "+random)
+	}
+
+	trials := make([]trial, 0, len(candidates))
+	for i, c := range candidates {
+		log.WithField("candidate", i+1).WithField("of", len(candidates)).WithField("parameters", c).Info("evaluating candidate...")
+		t := trial{Parameters: c}
+		result, err := evaluate(c)
+		if err != nil {
+			log.WithError(err).WithField("parameters", c).Warn("could not evaluate candidate")
+			t.Error = err.Error()
+		} else {
+			log.WithField("mae", result.MAE).WithField("rmse", result.RMSE).Info("evaluated candidate")
+			t.Result = &result
+		}
+		trials = append(trials, t)
+	}
+
+	// failed candidates are ranked last
+	sort.SliceStable(trials, func(i, j int) bool {
+		if trials[i].Result == nil || trials[j].Result == nil {
+			return trials[j].Result == nil && trials[i].Result != nil
+		}
+		return trials[i].Result.MAE < trials[j].Result.MAE
+	})
+	for i := range trials {
+		trials[i].Rank = i + 1
+	}
+
+	b, err := json.MarshalIndent(trials, "", "  ")
+	if err != nil {
+		log.WithError(err).Fatal("could not encode report")
+	}
+	if err := ioutil.WriteFile(config.Viper.GetString(PathTuneReport), b, 0644); err != nil {
+		log.WithError(err).Fatal("could not write report")
+	}
+	log.WithField("report", config.Viper.GetString(PathTuneReport)).Info("wrote report")
+
+	if len(trials) == 0 || trials[0].Result == nil {
+		log.Fatal("no candidate could be evaluated")
+	}
+	log.WithField("parameters", trials[0].Parameters).WithField("mae", trials[0].Result.MAE).Info("found best candidate")
+
+	if path := config.Viper.GetString(PathTunePromote); path != "" {
+		if err := promote(path, trials[0].Parameters); err != nil {
+			log.WithError(err).Fatal("could not promote best candidate")
+		}
+		log.WithField("config", path).Info("promoted best candidate; run 'openefs model build' with this configuration to apply it")
+	}
+}
+
+// searchValues returns the values to search for the parameter at path. Each
+// value of a list-valued parameter is a comma-separated list.
+func searchValues(path string) []string {
+	switch v := config.Viper.Get(path).(type) {
+	case []string:
+		return v
+	case []interface{}:
+		// set in a config-file, list-valued parameters as list of lists
+		values := make([]string, 0, len(v))
+		for _, e := range v {
+			if list, ok := e.([]interface{}); ok {
+				items := make([]string, 0, len(list))
+				for _, item := range list {
+					items = append(items, fmt.Sprint(item))
+				}
+				values = append(values, strings.Join(items, ","))
+			} else {
+				values = append(values, fmt.Sprint(e))
+			}
+		}
+		return values
+	default:
+		// viper cannot decode string-arrays
+		values, _ := config.RootCtx.PersistentFlags().GetStringArray(path)
+		return values
+	}
+}
+
+// evaluate runs a backtest for c in a separate process, as the configuration
+// cannot change within a process. All other settings are inherited.
+func evaluate(c search.Candidate) (production.Result, error) {
+	var result production.Result
+	exe, err := os.Executable()
+	if err != nil {
+		return result, err
+	}
+
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	args := append([]string{backtestCmd.Name()}, inheritedArgs(os.Args[1:], names)...)
+	for _, name := range names {
+		args = append(args, "--"+name+"="+c[name])
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return result, lastLine(err, stderr.String())
+	}
+	err = json.Unmarshal(stdout.Bytes(), &result)
+	return result, err
+}
+
+// inheritedArgs returns args without the tune-command and without the flags
+// named by overridden.
+func inheritedArgs(args []string, overridden []string) []string {
+	inherited := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == tuneCmd.Name() {
+			continue
+		}
+		skip := false
+		for _, name := range overridden {
+			if a == "--"+name {
+				// the value is the next argument
+				skip = true
+				i++
+				break
+			}
+			if strings.HasPrefix(a, "--"+name+"=") {
+				skip = true
+				break
+			}
+		}
+		if !skip {
+			inherited = append(inherited, a)
+		}
+	}
+	return inherited
+}
+
+// lastLine adds the last line of out, which holds the cause of a failed
+// command, to err.
+func lastLine(err error, out string) error {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if line := lines[len(lines)-1]; line != "" {
+		return &commandError{err: err, out: line}
+	}
+	return err
+}
+
+type commandError struct {
+	err error
+	out string
+}
+
+func (e *commandError) Error() string {
+	return e.err.Error() + ": " + e.out
+}
+
+// promote writes parameters to the config-file at path. Other settings in the
+// file are kept.
+func promote(path string, parameters search.Candidate) error {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if _, err := os.Stat(path); err == nil {
+		if err := v.ReadInConfig(); err != nil {
+			return err
+		}
+	}
+	for name, value := range parameters {
+		v.Set(name, value)
+	}
+	for _, name := range []string{production.PathFeatures, production.PathSpecLayers} {
+		if value, ok := parameters[name]; ok {
+			v.Set(name, strings.Split(value, ","))
+		}
+	}
+	return v.WriteConfigAs(path)
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/theMomax/openefs/models/production"
+	"github.com/theMomax/openefs/utils/search"
+)
+
+func TestInheritedArgs(t *testing.T) {
+	overridden := []string{"models.production.spec.units", "models.production.features"}
+	cases := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "tune only",
+			args: []string{"tune"},
+			want: []string{},
+		},
+		{
+			name: "other flags",
+			args: []string{"--backtest.data", "data.csv", "tune", "--tune.trials=5"},
+			want: []string{"--backtest.data", "data.csv", "--tune.trials=5"},
+		},
+		{
+			name: "overridden with separate value",
+			args: []string{"tune", "--models.production.spec.units", "64", "--backtest.folds", "3"},
+			want: []string{"--backtest.folds", "3"},
+		},
+		{
+			name: "overridden with inline value",
+			args: []string{"--models.production.features=production,cloudCover", "tune", "--backtest.folds=3"},
+			want: []string{"--backtest.folds=3"},
+		},
+		{
+			name: "overridden twice",
+			args: []string{"--models.production.spec.units=32", "tune", "--models.production.spec.units", "64"},
+			want: []string{},
+		},
+		{
+			name: "flag sharing a prefix with an overridden one",
+			args: []string{"--models.production.spec.unitsx=1", "--models.production.spec", "x"},
+			want: []string{"--models.production.spec.unitsx=1", "--models.production.spec", "x"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.want, inheritedArgs(c.args, overridden))
+		})
+	}
+}
+
+func TestPromote(t *testing.T) {
+	cases := []struct {
+		name     string
+		existing string
+		ext      string
+	}{
+		{name: "new yaml", ext: ".yaml"},
+		{name: "existing yaml", existing: "server:\n  port: 9000\nmodels:\n  production:\n    spec:\n      units: 8\n", ext: ".yaml"},
+		{name: "existing json", existing: `{"server": {"port": 9000}, "models": {"production": {"spec": {"units": 8}}}}`, ext: ".json"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "openefs-promote")
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "openefs"+c.ext)
+			if c.existing != "" {
+				assert.NoError(t, ioutil.WriteFile(path, []byte(c.existing), 0644))
+			}
+
+			assert.NoError(t, promote(path, search.Candidate{
+				production.PathSpecUnits:  "32",
+				production.PathFeatures:   "production,cloudCover",
+				production.PathSpecLayers: "lstm,dense",
+			}))
+
+			v := viper.New()
+			v.SetConfigFile(path)
+			if !assert.NoError(t, v.ReadInConfig()) {
+				return
+			}
+			assert.Equal(t, 32, v.GetInt(production.PathSpecUnits))
+			assert.Equal(t, []string{"production", "cloudCover"}, v.GetStringSlice(production.PathFeatures))
+			assert.Equal(t, []string{"lstm", "dense"}, v.GetStringSlice(production.PathSpecLayers))
+			if c.existing != "" {
+				// unrelated settings are kept
+				assert.Equal(t, 9000, v.GetInt("server.port"))
+			}
+		})
+	}
+}
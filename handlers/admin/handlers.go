@@ -0,0 +1,37 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	models "github.com/theMomax/openefs/models/production"
+	"github.com/theMomax/openefs/utils/breaker"
+)
+
+// Register takes care of registering all handler functions to the router.
+func Register(r *gin.RouterGroup) {
+	g := r.Group("admin")
+	g.GET("/breaker", handleBreakerRequest)
+	g.POST("/breaker/reset", handleBreakerReset)
+}
+
+// breakerStatus describes the circuit breaker guarding the production-model.
+type breakerStatus struct {
+	breaker.Status
+	// Fallback is the forecaster used while the breaker is open.
+	Fallback string `json:"fallback"`
+}
+
+func handleBreakerRequest(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, breakerStatus{
+		Status:   models.BreakerStatus(),
+		Fallback: models.Fallback(),
+	})
+}
+
+// handleBreakerReset closes the breaker, e.g. after the production-model was
+// repaired, and responds with its new status.
+func handleBreakerReset(ctx *gin.Context) {
+	models.ResetBreaker()
+	handleBreakerRequest(ctx)
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/theMomax/openefs/handlers/admin"
 	"github.com/theMomax/openefs/handlers/input"
 	"github.com/theMomax/openefs/handlers/output"
 )
@@ -11,4 +12,5 @@ func Register(r *gin.RouterGroup) {
 	g := r.Group("v1")
 	input.Register(g)
 	output.Register(g)
+	admin.Register(g)
 }
@@ -2,7 +2,9 @@ package input
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/theMomax/openefs/handlers/input/price"
 	"github.com/theMomax/openefs/handlers/input/production"
+	"github.com/theMomax/openefs/handlers/input/validation"
 	"github.com/theMomax/openefs/handlers/input/weather"
 )
 
@@ -11,4 +13,6 @@ func Register(r *gin.RouterGroup) {
 	g := r.Group("input")
 	production.Register(g)
 	weather.Register(g)
+	price.Register(g)
+	validation.Register(g)
 }
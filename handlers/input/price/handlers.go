@@ -0,0 +1,93 @@
+package price
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/theMomax/openefs/cache/price"
+	"github.com/theMomax/openefs/handlers/input/validation"
+)
+
+// maxBulkSize limits the amount of prices accepted by a single bulk-request.
+const maxBulkSize = 10000
+
+// entry is a single element of a bulk-request.
+type entry struct {
+	// Time is the unix-timestamp the prices are associated with.
+	Time int64 `json:"time" binding:"required"`
+	price.Data
+}
+
+// Register takes care of registering all handler functions to the router.
+func Register(r *gin.RouterGroup) {
+	g := r.Group("price")
+	g.POST("/:unixtimestamp/", handlePriceInput)
+	g.POST("/", handleBulkPriceInput)
+}
+
+func handlePriceInput(ctx *gin.Context) {
+	unixsecs, err := strconv.ParseInt(ctx.Param("unixtimestamp"), 10, 64)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	timestamp := time.Unix(unixsecs, 0)
+
+	var data price.Data
+	if err := ctx.ShouldBind(&data); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := validation.Price(timestamp, &data); err != nil {
+		validation.Abort(ctx, err)
+		return
+	}
+
+	price.Update(timestamp, data)
+	ctx.Status(http.StatusOK)
+}
+
+// handleBulkPriceInput accepts a JSON-array of prices. The prices are only
+// stored, if all of them are valid.
+func handleBulkPriceInput(ctx *gin.Context) {
+	var entries []entry
+	if err := ctx.ShouldBindJSON(&entries); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	if len(entries) > maxBulkSize {
+		ctx.AbortWithError(http.StatusBadRequest, errors.New("at most "+strconv.Itoa(maxBulkSize)+" prices may be given at once")).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	invalid := &validation.Error{
+		Message:    "invalid price-data",
+		Violations: make([]validation.Violation, 0),
+	}
+	for i, e := range entries {
+		err := validation.Price(time.Unix(e.Time, 0), &e.Data)
+		if v, ok := err.(*validation.Error); ok {
+			for _, violation := range v.Violations {
+				violation.Field = "[" + strconv.Itoa(i) + "]." + violation.Field
+				invalid.Violations = append(invalid.Violations, violation)
+			}
+		} else if err != nil {
+			validation.Abort(ctx, err)
+			return
+		}
+	}
+	if len(invalid.Violations) > 0 {
+		validation.Abort(ctx, invalid)
+		return
+	}
+
+	for _, e := range entries {
+		price.Update(time.Unix(e.Time, 0), e.Data)
+	}
+	ctx.Status(http.StatusOK)
+}
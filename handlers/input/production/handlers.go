@@ -9,6 +9,7 @@ import (
 	"github.com/theMomax/openefs/utils/metadata"
 
 	"github.com/gin-gonic/gin"
+	"github.com/theMomax/openefs/handlers/input/validation"
 	models "github.com/theMomax/openefs/models/production"
 
 	syncutils "github.com/theMomax/openefs/utils/synchronization"
@@ -31,7 +32,15 @@ func handleBasicProductionInput(ctx *gin.Context) {
 	timestamp := time.Unix(unixsecs, 0)
 
 	var data models.Data
-	ctx.Bind(&data)
+	if err := ctx.ShouldBind(&data); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := validation.Production(timestamp, &data); err != nil {
+		validation.Abort(ctx, err)
+		return
+	}
 
 	syncutils.AttachID(func(id uint64) {
 		if ok := models.UpdateProduction(&update{
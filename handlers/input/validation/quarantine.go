@@ -0,0 +1,118 @@
+package validation
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/theMomax/openefs/config"
+	timeutils "github.com/theMomax/openefs/utils/time"
+)
+
+// Config paths
+const (
+	PathQuarantineSize = "handlers.input.validation.quarantinesize"
+	PathQuarantineFile = "handlers.input.validation.quarantinefile"
+)
+
+// Kinds of quarantined data
+const (
+	kindProduction = "production"
+	kindWeather    = "weather"
+	kindPrice      = "price"
+)
+
+func init() {
+	config.RootCtx.PersistentFlags().Uint(PathQuarantineSize, 1000, "the amount of rejected inputs kept in memory for inspection")
+	config.Viper.BindPFlag(PathQuarantineSize, config.RootCtx.PersistentFlags().Lookup(PathQuarantineSize))
+
+	config.RootCtx.PersistentFlags().String(PathQuarantineFile, "", "file to which rejected inputs are appended as JSON lines (empty for none)")
+	config.Viper.BindPFlag(PathQuarantineFile, config.RootCtx.PersistentFlags().Lookup(PathQuarantineFile))
+
+	config.OnInitialize(func() {
+		log = config.NewLogger()
+	})
+
+	config.OnInitialize(func() {
+		quarantineSize = config.Viper.GetUint(PathQuarantineSize)
+		quarantineFile = config.Viper.GetString(PathQuarantineFile)
+	})
+}
+
+var log *logrus.Logger
+
+var (
+	quarantineSize uint
+	quarantineFile string
+)
+
+// Record is a rejected input.
+type Record struct {
+	Kind       string                 `json:"kind"`
+	Time       time.Time              `json:"time"`
+	Received   time.Time              `json:"received"`
+	Data       map[string]interface{} `json:"data"`
+	Violations []Violation            `json:"violations"`
+}
+
+var records = make([]Record, 0)
+var rm = &sync.RWMutex{}
+
+// Register takes care of registering all handler functions to the router.
+func Register(r *gin.RouterGroup) {
+	r.GET("/quarantine", handleQuarantineRequest)
+}
+
+// Quarantined returns the most recently rejected inputs.
+func Quarantined() []Record {
+	rm.RLock()
+	defer rm.RUnlock()
+	return append([]Record{}, records...)
+}
+
+func handleQuarantineRequest(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, Quarantined())
+}
+
+func quarantine(kind string, t time.Time, data map[string]interface{}, violations []Violation) {
+	r := Record{
+		Kind:       kind,
+		Time:       t,
+		Received:   timeutils.Now(),
+		Data:       data,
+		Violations: violations,
+	}
+
+	log.WithField("kind", kind).WithField("time", t).WithField("violations", violations).Warn("quarantined invalid input")
+
+	rm.Lock()
+	if quarantineSize > 0 {
+		if uint(len(records)) >= quarantineSize {
+			records = append(records[:0], records[uint(len(records))-quarantineSize+1:]...)
+		}
+		records = append(records, r)
+	}
+	rm.Unlock()
+
+	if quarantineFile == "" {
+		return
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		log.WithError(err).Error("could not encode quarantined input")
+		return
+	}
+	f, err := os.OpenFile(quarantineFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.WithError(err).WithField("file", quarantineFile).Error("could not open quarantine-file")
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		log.WithError(err).WithField("file", quarantineFile).Error("could not write quarantine-file")
+	}
+}
@@ -0,0 +1,102 @@
+package validation
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// series keeps track of the most recently accepted values of a time-series
+// for detecting spikes and stuck sensors.
+type series struct {
+	values     []float64
+	window     uint
+	stuckCount uint
+	// peak is the installed peak-power of the system producing the values
+	peak float64
+	m    *sync.Mutex
+}
+
+// productionSeries holds the series of each array. The site's series is stored
+// for the empty array-id.
+var (
+	productionSeries = make(map[string]*series)
+	psm              = &sync.Mutex{}
+)
+
+// seriesOf returns the series of the given array, which has the given
+// peak-power.
+func seriesOf(array string, peak float64) *series {
+	psm.Lock()
+	defer psm.Unlock()
+	if productionSeries[array] == nil {
+		productionSeries[array] = newSeries(window, stuckCount, peak)
+	}
+	return productionSeries[array]
+}
+
+func newSeries(window, stuckCount uint, peak float64) *series {
+	size := window
+	if stuckCount > size {
+		size = stuckCount
+	}
+	return &series{
+		values:     make([]float64, 0, size),
+		window:     window,
+		stuckCount: stuckCount,
+		peak:       peak,
+		m:          &sync.Mutex{},
+	}
+}
+
+// check returns the violations v would cause in respect to the recent values.
+func (s *series) check(v float64) []Violation {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	violations := make([]Violation, 0)
+
+	if spikeFactor > 0 && s.peak > 0 && s.window > 0 && uint(len(s.values)) >= s.window {
+		m := median(s.values[uint(len(s.values))-s.window:])
+		if math.Abs(v-m) > spikeFactor*s.peak {
+			violations = append(violations, Violation{"Power", format(v), "deviates from the recent median " + format(m) + " by more than " + format(spikeFactor*s.peak)})
+		}
+	}
+
+	if s.stuckCount > 0 && v != 0 && uint(len(s.values)) >= s.stuckCount {
+		stuck := true
+		for _, p := range s.values[uint(len(s.values))-s.stuckCount:] {
+			if p != v {
+				stuck = false
+				break
+			}
+		}
+		if stuck {
+			violations = append(violations, Violation{"Power", format(v), "was reported more than " + format(float64(s.stuckCount)) + " times in a row"})
+		}
+	}
+
+	return violations
+}
+
+// apply adds v to the recent values.
+func (s *series) apply(v float64) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if cap(s.values) == 0 {
+		return
+	}
+	if len(s.values) == cap(s.values) {
+		s.values = append(s.values[:0], s.values[1:]...)
+	}
+	s.values = append(s.values, v)
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	if len(sorted)%2 == 1 {
+		return sorted[len(sorted)/2]
+	}
+	return (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+}
@@ -0,0 +1,219 @@
+package validation
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/theMomax/openefs/cache/price"
+	"github.com/theMomax/openefs/config"
+	productionmodels "github.com/theMomax/openefs/models/production"
+	weathermodels "github.com/theMomax/openefs/models/production/weather"
+)
+
+// Config paths
+const (
+	PathTolerance   = "handlers.input.validation.tolerance"
+	PathSpikeFactor = "handlers.input.validation.spikefactor"
+	PathStuckCount  = "handlers.input.validation.stuckcount"
+	PathWindow      = "handlers.input.validation.window"
+)
+
+func init() {
+	config.RootCtx.PersistentFlags().Float64(PathTolerance, 0.2, "the fraction by which production-values may exceed the installed peak-power before they are rejected")
+	config.Viper.BindPFlag(PathTolerance, config.RootCtx.PersistentFlags().Lookup(PathTolerance))
+
+	config.RootCtx.PersistentFlags().Float64(PathSpikeFactor, 0, "the fraction of the installed peak-power by which a production-value may deviate from the median of the recent values before it is rejected as a spike (0 disables spike-detection)")
+	config.Viper.BindPFlag(PathSpikeFactor, config.RootCtx.PersistentFlags().Lookup(PathSpikeFactor))
+
+	config.RootCtx.PersistentFlags().Uint(PathStuckCount, 0, "the amount of identical non-zero production-values in a row, after which further identical values are rejected as coming from a stuck sensor (0 disables stuck-sensor-detection)")
+	config.Viper.BindPFlag(PathStuckCount, config.RootCtx.PersistentFlags().Lookup(PathStuckCount))
+
+	config.RootCtx.PersistentFlags().Uint(PathWindow, 6, "the amount of recent production-values considered by spike-detection")
+	config.Viper.BindPFlag(PathWindow, config.RootCtx.PersistentFlags().Lookup(PathWindow))
+
+	config.OnInitialize(func() {
+		maximumProductionPower = config.Viper.GetFloat64(productionmodels.PathMaximumProductionPower)
+		tolerance = config.Viper.GetFloat64(PathTolerance)
+		if tolerance < 0 {
+			config.InvalidConfiguration(PathTolerance, "[0, +inf)")
+		}
+		spikeFactor = config.Viper.GetFloat64(PathSpikeFactor)
+		if spikeFactor < 0 {
+			config.InvalidConfiguration(PathSpikeFactor, "[0, +inf)")
+		}
+		stuckCount = config.Viper.GetUint(PathStuckCount)
+		window = config.Viper.GetUint(PathWindow)
+		productionSeries = make(map[string]*series)
+	})
+}
+
+var (
+	maximumProductionPower float64
+	tolerance              float64
+	spikeFactor            float64
+	stuckCount             uint
+	window                 uint
+)
+
+// Physical bounds of weather-values
+const (
+	minTemperature = -100.0
+	maxTemperature = 70.0
+)
+
+// Violation describes why a single value was rejected.
+type Violation struct {
+	Field  string `json:"field"`
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+}
+
+// Error is returned if some input is invalid. It lists all violations found.
+type Error struct {
+	Message    string      `json:"error"`
+	Violations []Violation `json:"violations"`
+}
+
+func (e *Error) Error() string {
+	reasons := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		reasons[i] = v.Field + " " + v.Reason
+	}
+	return e.Message + ": " + strings.Join(reasons, ", ")
+}
+
+// field describes a single value and its physical bounds.
+type field struct {
+	name     string
+	value    float64
+	min, max float64
+}
+
+// Production validates the given production-data associated with time t. If
+// the data belongs to a single array, the array's peak-power applies instead
+// of the site's. If the data is invalid, it is quarantined and an *Error is
+// returned.
+func Production(t time.Time, data *productionmodels.Data) error {
+	peak := maximumProductionPower
+	violations := make([]Violation, 0)
+	if data.Array != "" {
+		a, ok := productionmodels.ArrayByID(data.Array)
+		if !ok {
+			violations = append(violations, Violation{"Array", data.Array, "is not a configured array"})
+		}
+		peak = a.PeakPower
+	}
+
+	max := math.Inf(1)
+	if peak > 0 {
+		max = peak * (1 + tolerance)
+	}
+	fields := []field{{"Power", data.Power, 0, max}}
+
+	violations = append(violations, check(fields)...)
+	if len(violations) == 0 {
+		violations = seriesOf(data.Array, peak).check(data.Power)
+	}
+	if len(violations) > 0 {
+		return reject(kindProduction, t, fields, violations)
+	}
+	seriesOf(data.Array, peak).apply(data.Power)
+	return nil
+}
+
+// Weather validates the given weather-data associated with time t. If the
+// data is invalid, it is quarantined and an *Error is returned.
+func Weather(t time.Time, data *weathermodels.Data) error {
+	inf := math.Inf(1)
+	fields := []field{
+		{"CloudCover", data.CloudCover, 0, 1},
+		{"PrecipitationProbability", data.PrecipitationProbability, 0, 1},
+		{"PrecipitationIntensity", data.PrecipitationIntensity, 0, inf},
+		{"WindSpeed", data.WindSpeed, 0, inf},
+		{"WindGust", data.WindGust, 0, inf},
+		{"ApparentTemperature", data.ApparentTemperature, minTemperature, maxTemperature},
+		{"Temperature", data.Temperature, minTemperature, maxTemperature},
+		{"Humidity", data.Humidity, 0, 1},
+		{"DewPoint", data.DewPoint, minTemperature, maxTemperature},
+		{"Visibility", data.Visibility, 0, inf},
+		{"UVIndex", data.UVIndex, 0, inf},
+	}
+
+	if violations := check(fields); len(violations) > 0 {
+		return reject(kindWeather, t, fields, violations)
+	}
+	return nil
+}
+
+// Price validates the given prices associated with time t. Prices may be
+// negative, but must be finite. If the data is invalid, it is quarantined and
+// an *Error is returned.
+func Price(t time.Time, data *price.Data) error {
+	inf := math.Inf(1)
+	fields := []field{
+		{"Import", data.Import, -inf, inf},
+		{"Export", data.Export, -inf, inf},
+	}
+
+	if violations := check(fields); len(violations) > 0 {
+		return reject(kindPrice, t, fields, violations)
+	}
+	return nil
+}
+
+// Abort aborts the request. If err is an *Error, it responds with status 422
+// and the list of violations.
+func Abort(ctx *gin.Context, err error) {
+	if e, ok := err.(*Error); ok {
+		ctx.Error(err)
+		ctx.AbortWithStatusJSON(http.StatusUnprocessableEntity, e)
+		return
+	}
+	ctx.AbortWithError(http.StatusInternalServerError, err)
+}
+
+func check(fields []field) []Violation {
+	violations := make([]Violation, 0)
+	for _, f := range fields {
+		switch {
+		case math.IsNaN(f.value) || math.IsInf(f.value, 0):
+			violations = append(violations, Violation{f.name, format(f.value), "must be a finite number"})
+		case f.value < f.min:
+			violations = append(violations, Violation{f.name, format(f.value), "must be at least " + format(f.min)})
+		case f.value > f.max:
+			violations = append(violations, Violation{f.name, format(f.value), "must be at most " + format(f.max)})
+		}
+	}
+	return violations
+}
+
+func reject(kind string, t time.Time, fields []field, violations []Violation) error {
+	values := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		values[f.name] = number(f.value)
+	}
+	err := &Error{
+		Message:    fmt.Sprintf("invalid %s-data", kind),
+		Violations: violations,
+	}
+	quarantine(kind, t, values, violations)
+	return err
+}
+
+func format(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// number returns f, if it can be represented in JSON and its string
+// representation otherwise.
+func number(f float64) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return format(f)
+	}
+	return f
+}
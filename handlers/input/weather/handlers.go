@@ -9,6 +9,7 @@ import (
 	"github.com/theMomax/openefs/utils/metadata"
 
 	"github.com/gin-gonic/gin"
+	"github.com/theMomax/openefs/handlers/input/validation"
 	productionmodels "github.com/theMomax/openefs/models/production"
 	models "github.com/theMomax/openefs/models/production/weather"
 
@@ -32,7 +33,15 @@ func handleBasicWeatherInput(ctx *gin.Context) {
 	timestamp := time.Unix(unixsecs, 0)
 
 	var data models.Data
-	ctx.Bind(&data)
+	if err := ctx.ShouldBind(&data); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := validation.Weather(timestamp, &data); err != nil {
+		validation.Abort(ctx, err)
+		return
+	}
 
 	syncutils.AttachID(func(id uint64) {
 
@@ -0,0 +1,49 @@
+package dispatch
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/theMomax/openefs/optimization/dispatch"
+)
+
+// Register takes care of registering all handler functions to the router.
+func Register(r *gin.RouterGroup) {
+	r.GET("/dispatch", handleDispatchRequest)
+	r.GET("/dispatch/settings", handleSettingsRequest)
+	r.PUT("/dispatch/settings", handleSettingsUpdate)
+}
+
+func handleDispatchRequest(ctx *gin.Context) {
+	plan := dispatch.CurrentPlan()
+	if plan == nil {
+		ctx.Status(http.StatusNoContent)
+		return
+	}
+	ctx.JSON(http.StatusOK, plan)
+}
+
+func handleSettingsRequest(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, dispatch.CurrentSettings())
+}
+
+// handleSettingsUpdate replaces the dispatch-settings (e.g. to report the
+// battery's current state of charge) and responds with the re-computed plan.
+func handleSettingsUpdate(ctx *gin.Context) {
+	var s dispatch.Settings
+	if err := ctx.ShouldBindJSON(&s); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	plan, err := dispatch.Configure(s)
+	if err != nil {
+		ctx.AbortWithError(http.StatusUnprocessableEntity, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	if plan == nil {
+		ctx.Status(http.StatusNoContent)
+		return
+	}
+	ctx.JSON(http.StatusOK, plan)
+}
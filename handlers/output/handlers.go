@@ -2,6 +2,7 @@ package output
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/theMomax/openefs/handlers/output/dispatch"
 	"github.com/theMomax/openefs/handlers/output/production"
 )
 
@@ -9,4 +10,5 @@ import (
 func Register(r *gin.RouterGroup) {
 	g := r.Group("output")
 	production.Register(g)
+	dispatch.Register(g)
 }
@@ -0,0 +1,148 @@
+package production
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	cache "github.com/theMomax/openefs/cache/production"
+	errorcache "github.com/theMomax/openefs/cache/production/error"
+	models "github.com/theMomax/openefs/models/production"
+	"github.com/theMomax/openefs/optimization/window"
+	timeutils "github.com/theMomax/openefs/utils/time"
+)
+
+// Server-sent event types of the best-window-subscription
+const (
+	eventWindow      = "window"
+	eventUnavailable = "unavailable"
+	eventExpired     = "expired"
+)
+
+// windowRequest holds the parameters of a best-window-request.
+type windowRequest struct {
+	load  window.Load
+	until time.Time
+}
+
+func handleBestWindowRequest(ctx *gin.Context) {
+	req, err := parseWindowRequest(ctx)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	w, err := bestWindow(req)
+	if err == window.ErrIllegalRequest {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	} else if err != nil {
+		ctx.AbortWithError(http.StatusNotFound, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, w)
+}
+
+// handleBestWindowSubscription streams the recommended window as server-sent
+// events. A new event is sent each time the recommendation changes. The stream
+// ends when the deadline can no longer be met, even if the cache is not
+// updated anymore.
+func handleBestWindowSubscription(ctx *gin.Context) {
+	req, err := parseWindowRequest(ctx)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	changed := make(chan struct{}, 1)
+	id := cache.Subscribe(func(models.Update) {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}, nil, nil)
+	defer cache.Unsubscribe(id)
+
+	// the latest start, at which the load still finishes before the deadline
+	expired := timeutils.After(req.until.Add(-req.load.Duration).Sub(timeutils.Now()))
+
+	var last *window.Window
+	unavailable := false
+	ctx.Stream(func(io.Writer) bool {
+		select {
+		case <-ctx.Request.Context().Done():
+			return false
+		case <-expired:
+			ctx.SSEvent(eventExpired, window.ErrIllegalRequest.Error())
+			return false
+		case <-changed:
+		}
+
+		w, err := bestWindow(req)
+		switch err {
+		case nil:
+			// windows starting now move with the current time, thus only
+			// changes by at least one step count
+			if last == nil || !models.Round(last.From).Equal(models.Round(w.From)) {
+				ctx.SSEvent(eventWindow, w)
+				last = &w
+				unavailable = false
+			}
+		case window.ErrIllegalRequest:
+			ctx.SSEvent(eventExpired, err.Error())
+			return false
+		default:
+			if !unavailable {
+				ctx.SSEvent(eventUnavailable, err.Error())
+				last = nil
+				unavailable = true
+			}
+		}
+		return true
+	})
+}
+
+func parseWindowRequest(ctx *gin.Context) (windowRequest, error) {
+	duration, err := time.ParseDuration(ctx.Query("duration"))
+	if err != nil {
+		return windowRequest{}, err
+	}
+
+	energy, err := strconv.ParseFloat(ctx.Query("energy"), 64)
+	if err != nil {
+		return windowRequest{}, err
+	}
+
+	untilunixsecs, err := strconv.ParseInt(ctx.Query("until"), 10, 64)
+	if err != nil {
+		return windowRequest{}, err
+	}
+
+	return windowRequest{
+		load: window.Load{
+			Duration: duration,
+			Energy:   energy,
+		},
+		until: time.Unix(untilunixsecs, 0),
+	}, nil
+}
+
+// bestWindow computes the best window from now on based on the cached
+// production-forecast. The uncertainty of a derived value is the MAE at its
+// lead-time.
+func bestWindow(req windowRequest) (window.Window, error) {
+	now := timeutils.Now()
+	return window.Best(now, req.until, req.load, stepSize, func(t time.Time) (float64, float64, bool) {
+		u := cache.Update(t)
+		if u == nil || u.Data() == nil {
+			return 0, 0, false
+		}
+		if !u.IsDerived() {
+			return u.Data().Power, 0, true
+		}
+		mae, _ := errorcache.MAE(models.Round(t).Sub(models.Round(now)))
+		return u.Data().Power, mae, true
+	})
+}
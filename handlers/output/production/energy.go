@@ -0,0 +1,148 @@
+package production
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	cache "github.com/theMomax/openefs/cache/production"
+	models "github.com/theMomax/openefs/models/production"
+	"github.com/theMomax/openefs/utils/convert"
+	timeutils "github.com/theMomax/openefs/utils/time"
+)
+
+// Values considered during integration
+const (
+	includeAll        = "all"
+	includeDerived    = "derived"
+	includeNonDerived = "nonderived"
+)
+
+// Kinds of power
+const (
+	// powerPotential is the power, that could be produced without
+	// curtailment.
+	powerPotential = "potential"
+	// powerCurtailed is the power, that is expected to be produced, if the
+	// site's export limit is enforced.
+	powerCurtailed = "curtailed"
+)
+
+// maxBuckets limits the amount of buckets returned by a single request.
+const maxBuckets = 10000
+
+// bucket is the energy produced during a single calendar period.
+type bucket struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	convert.Energy
+	// Value is nil if there are no prices for the period.
+	Value *value `json:"value"`
+}
+
+func handleEnergyBreakdownRequest(ctx *gin.Context) {
+	fromunixsecs, err := strconv.ParseInt(ctx.Param("from"), 10, 64)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	tounixsecs, err := strconv.ParseInt(ctx.Param("to"), 10, 64)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	loc, err := location(ctx)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	power, err := powerFunc(ctx.DefaultQuery("include", includeAll), ctx.DefaultQuery("power", powerPotential))
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	from := time.Unix(fromunixsecs, 0).In(loc)
+	to := time.Unix(tounixsecs, 0).In(loc)
+	if from.After(to) {
+		ctx.AbortWithError(http.StatusBadRequest, convert.ErrIllegalTimestamps).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	granularity := ctx.Param("granularity")
+	buckets := make([]bucket, 0)
+	for start := from; start.Before(to); {
+		end, err := timeutils.NextPeriod(start, granularity)
+		if err != nil {
+			ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+			return
+		}
+		if end.After(to) {
+			end = to
+		}
+		if len(buckets) == maxBuckets {
+			ctx.AbortWithError(http.StatusBadRequest, errors.New("too many buckets")).SetType(gin.ErrorTypeBind)
+			return
+		}
+
+		e, err := convert.IntegrateSteps(start, end, stepSize, power)
+		if err != nil && !errors.Is(err, convert.ErrNoData) {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		v, err := valueOf(start, end, power)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		buckets = append(buckets, bucket{
+			From:   start,
+			To:     end,
+			Energy: e,
+			Value:  v,
+		})
+		start = end
+	}
+
+	ctx.JSON(http.StatusOK, buckets)
+}
+
+// powerFunc returns a function providing the cached power of the given kind
+// for each step. It only provides values of the given origin.
+func powerFunc(include, kind string) (func(time.Time) *float64, error) {
+	switch include {
+	case includeAll, includeDerived, includeNonDerived:
+	default:
+		return nil, errors.New("include must be one of: " + includeAll + ", " + includeDerived + ", " + includeNonDerived)
+	}
+	switch kind {
+	case powerPotential, powerCurtailed:
+	default:
+		return nil, errors.New("power must be one of: " + powerPotential + ", " + powerCurtailed)
+	}
+	return func(at time.Time) *float64 {
+		u := cache.Update(at)
+		if u == nil || u.Data() == nil {
+			return nil
+		}
+		if (include == includeDerived && !u.IsDerived()) || (include == includeNonDerived && u.IsDerived()) {
+			return nil
+		}
+		p := power(u, kind)
+		return &p
+	}, nil
+}
+
+// power returns u's power of the given kind. Measured values already are
+// curtailed and serve as the best known estimate of the potential.
+func power(u models.Update, kind string) float64 {
+	if kind == powerCurtailed && u.IsDerived() {
+		return models.Curtail(u.Data().Power)
+	}
+	return u.Data().Power
+}
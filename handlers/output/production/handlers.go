@@ -33,6 +33,9 @@ func Register(r *gin.RouterGroup) {
 	g := r.Group("production")
 	g.GET("/from/:from/to/:to", handleProductionRequest)
 	g.GET("/at/:at", handleProductionRequestAtTime)
+	g.GET("/series/from/:from/to/:to", handleSeriesRequest)
+	g.GET("/breakdown/:granularity/from/:from/to/:to", handleEnergyBreakdownRequest)
+	g.GET("/energy/:granularity", handleEnergyReportRequest)
 	g.GET("/day/relative/:at", handleProductionRequestAtDayRelative)
 	g.GET("/day/absolute/:at", handleProductionRequestAtDay)
 	g.GET("/day/avg/derived/relative/:at", func(ctx *gin.Context) {
@@ -48,6 +51,9 @@ func Register(r *gin.RouterGroup) {
 		handleProductionRequestAtDayAvg(ctx, false)
 	})
 	g.GET("/error", handleProductionError)
+	g.GET("/arrays", handleArraysRequest)
+	g.GET("/bestwindow", handleBestWindowRequest)
+	g.GET("/bestwindow/subscribe", handleBestWindowSubscription)
 }
 
 func handleProductionRequest(ctx *gin.Context) {
@@ -81,11 +87,14 @@ func handleProductionRequest(ctx *gin.Context) {
 	if err != nil {
 		if errors.Is(err, convert.ErrIllegalTimestamps) {
 			ctx.AbortWithError(http.StatusBadRequest, err)
+			return
 		}
 		if errors.Is(err, convert.ErrNoData) {
 			ctx.AbortWithError(http.StatusNoContent, err)
+			return
 		}
 		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
 	}
 
 	ctx.JSON(http.StatusOK, kWh)
@@ -100,12 +109,29 @@ func handleProductionRequestAtTime(ctx *gin.Context) {
 
 	at := time.Unix(atunixsecs, 0)
 
-	update := cache.Update(at)
+	kind := ctx.DefaultQuery("power", powerPotential)
+	if _, err := powerFunc(includeAll, kind); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	array, err := arrayOf(ctx)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	update := updateOf(array, at)
 	if update == nil || update.Data() == nil {
-		ctx.AbortWithError(http.StatusNoContent, err)
+		ctx.Status(http.StatusNoContent)
+		return
 	}
 
-	ctx.JSON(http.StatusOK, update.Data().Power)
+	if array != "" && kind == powerCurtailed {
+		ctx.JSON(http.StatusOK, update.Data().Power*curtailment(at))
+		return
+	}
+	ctx.JSON(http.StatusOK, power(update, kind))
 }
 
 func handleProductionRequestAtDay(ctx *gin.Context) {
@@ -115,15 +141,14 @@ func handleProductionRequestAtDay(ctx *gin.Context) {
 		return
 	}
 
-	at := time.Unix(atunixsecs, 0)
-	start := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
-	values := make([]*float64, 24)
-	for i := 0; i < 24; i++ {
-		if u := cache.Update(start.Add(time.Duration(i) * time.Hour)); u != nil && u.Data() != nil {
-			values[i] = &u.Data().Power
-		}
+	loc, err := location(ctx)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
 	}
-	ctx.JSON(http.StatusOK, values)
+
+	start, end := dayBounds(time.Unix(atunixsecs, 0).In(loc))
+	ctx.JSON(http.StatusOK, day(start, end))
 }
 
 func handleProductionRequestAtDayRelative(ctx *gin.Context) {
@@ -133,15 +158,14 @@ func handleProductionRequestAtDayRelative(ctx *gin.Context) {
 		return
 	}
 
-	at := timeutils.Now().Add(24 * time.Hour * time.Duration(atdays))
-	start := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
-	values := make([]*float64, 24)
-	for i := 0; i < 24; i++ {
-		if u := cache.Update(start.Add(time.Duration(i) * time.Hour)); u != nil && u.Data() != nil {
-			values[i] = &u.Data().Power
-		}
+	loc, err := location(ctx)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
 	}
-	ctx.JSON(http.StatusOK, values)
+
+	start, end := dayBounds(timeutils.Now().In(loc).AddDate(0, 0, int(atdays)))
+	ctx.JSON(http.StatusOK, day(start, end))
 }
 
 func handleProductionRequestAtDayAvg(ctx *gin.Context, derived bool) {
@@ -151,20 +175,14 @@ func handleProductionRequestAtDayAvg(ctx *gin.Context, derived bool) {
 		return
 	}
 
-	at := time.Unix(atunixsecs, 0)
-	start := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
-	values := make([]*float64, 24)
-	get := avgcache.GetNonDerived
-	if derived {
-		get = avgcache.GetDerived
+	loc, err := location(ctx)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
 	}
 
-	for i := 0; i < 24; i++ {
-		if v, ok := get(uint(start.Sub(timeutils.Now()).Truncate(24*time.Hour)/(24*time.Hour)), uint(i)); ok {
-			values[i] = &v
-		}
-	}
-	ctx.JSON(http.StatusOK, values)
+	start, end := dayBounds(time.Unix(atunixsecs, 0).In(loc))
+	ctx.JSON(http.StatusOK, avgDay(start, end, derived))
 }
 
 func handleProductionRequestAtDayAvgRelative(ctx *gin.Context, derived bool) {
@@ -174,25 +192,28 @@ func handleProductionRequestAtDayAvgRelative(ctx *gin.Context, derived bool) {
 		return
 	}
 
-	values := make([]*float64, 24)
-	get := avgcache.GetNonDerived
-	if derived {
-		get = avgcache.GetDerived
+	loc, err := location(ctx)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
 	}
 
-	for i := 0; i < 24; i++ {
-		if v, ok := get(uint(atdays), uint(i)); ok {
-			values[i] = &v
-		}
-	}
-	ctx.JSON(http.StatusOK, values)
+	start, end := dayBounds(timeutils.Now().In(loc).AddDate(0, 0, int(atdays)))
+	ctx.JSON(http.StatusOK, avgDay(start, end, derived))
 }
 
 func handleProductionError(ctx *gin.Context) {
+	array, err := arrayOf(ctx)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	// the i-th value is the error for predictions made (i+1) steps ahead
 	errs := make([]float64, 0)
 	d := stepSize
 	for {
-		e, ok := errorcache.MAE(d)
+		e, ok := errorcache.ArrayMAE(array, d)
 		if !ok {
 			break
 		}
@@ -201,3 +222,83 @@ func handleProductionError(ctx *gin.Context) {
 	}
 	ctx.JSON(http.StatusOK, errs)
 }
+
+func handleArraysRequest(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, models.Arrays())
+}
+
+// arrayOf returns the id of the array requested using the array
+// query-parameter. It is empty, if the whole site is requested.
+func arrayOf(ctx *gin.Context) (string, error) {
+	array := ctx.Query("array")
+	if _, ok := models.ArrayByID(array); array != "" && !ok {
+		return "", errors.New("array " + array + " is not configured")
+	}
+	return array, nil
+}
+
+// updateOf returns the cached update of the given array at t. The site's
+// update is returned for the empty array-id.
+func updateOf(array string, t time.Time) models.Update {
+	if array == "" {
+		return cache.Update(t)
+	}
+	return cache.ArrayUpdate(array, t)
+}
+
+// curtailment returns the fraction of the site's potential production at t,
+// that is expected to remain after curtailment. Curtailment is assumed to
+// affect all arrays proportionally.
+func curtailment(t time.Time) float64 {
+	u := cache.Update(t)
+	if u == nil || u.Data() == nil || !u.IsDerived() || u.Data().Power <= 0 {
+		return 1
+	}
+	return models.Curtail(u.Data().Power) / u.Data().Power
+}
+
+// location returns the time zone requested using the tz query-parameter. It
+// defaults to the site's time zone.
+func location(ctx *gin.Context) (*time.Location, error) {
+	if tz, ok := ctx.GetQuery("tz"); ok {
+		return time.LoadLocation(tz)
+	}
+	return models.Location(), nil
+}
+
+// dayBounds returns the start of the day at belongs to and the start of the
+// following day in at's time zone. The day may be 23 or 25 hours long.
+func dayBounds(at time.Time) (start, end time.Time) {
+	start = time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
+	end = time.Date(at.Year(), at.Month(), at.Day()+1, 0, 0, 0, 0, at.Location())
+	return
+}
+
+// day returns the power for each step between start and end.
+func day(start, end time.Time) []*float64 {
+	values := make([]*float64, end.Sub(start)/stepSize)
+	for i := range values {
+		if u := cache.Update(start.Add(time.Duration(i) * stepSize)); u != nil && u.Data() != nil {
+			values[i] = &u.Data().Power
+		}
+	}
+	return values
+}
+
+// avgDay returns the average (non-)derived power for each step between start
+// and end.
+func avgDay(start, end time.Time, derived bool) []*float64 {
+	get := avgcache.GetNonDerived
+	if derived {
+		get = avgcache.GetDerived
+	}
+
+	values := make([]*float64, end.Sub(start)/stepSize)
+	for i := range values {
+		t := start.Add(time.Duration(i) * stepSize)
+		if v, ok := get(models.DaysAhead(t), models.StepOfDay(t)); ok {
+			values[i] = &v
+		}
+	}
+	return values
+}
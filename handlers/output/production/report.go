@@ -0,0 +1,110 @@
+package production
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/theMomax/openefs/utils/convert"
+	timeutils "github.com/theMomax/openefs/utils/time"
+)
+
+// maxReportPeriods limits the amount of periods returned by a single request.
+const maxReportPeriods = 366
+
+// report compares the energy that was actually produced during a calendar
+// period with the energy, that is forecasted for the rest of it.
+type report struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Actual is the energy calculated from provided values.
+	Actual convert.Energy `json:"actual"`
+	// Forecast is the energy calculated from predicted values.
+	Forecast convert.Energy `json:"forecast"`
+	// Total is the sum of Actual and Forecast.
+	Total convert.Energy `json:"total"`
+	// Value holds the financial value of Actual, Forecast and Total. Each of
+	// them is nil if there are no prices for the period.
+	Value struct {
+		Actual   *value `json:"actual"`
+		Forecast *value `json:"forecast"`
+		Total    *value `json:"total"`
+	} `json:"value"`
+}
+
+func handleEnergyReportRequest(ctx *gin.Context) {
+	at := timeutils.Now()
+	if a, ok := ctx.GetQuery("at"); ok {
+		atunixsecs, err := strconv.ParseInt(a, 10, 64)
+		if err != nil {
+			ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+			return
+		}
+		at = time.Unix(atunixsecs, 0)
+	}
+
+	count, err := strconv.ParseUint(ctx.DefaultQuery("count", "1"), 10, 64)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	if count == 0 || count > maxReportPeriods {
+		ctx.AbortWithError(http.StatusBadRequest, errors.New("count must be within [1, "+strconv.Itoa(maxReportPeriods)+"]")).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	loc, err := location(ctx)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	kind := ctx.DefaultQuery("power", powerPotential)
+	if _, err := powerFunc(includeAll, kind); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	granularity := ctx.Param("granularity")
+	start, err := timeutils.PeriodStart(at.In(loc), granularity)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	reports := make([]report, 0, count)
+	for i := uint64(0); i < count; i++ {
+		end, _ := timeutils.NextPeriod(start, granularity)
+		r := report{
+			From: start,
+			To:   end,
+		}
+		for _, e := range []struct {
+			include string
+			energy  *convert.Energy
+			value   **value
+		}{
+			{includeNonDerived, &r.Actual, &r.Value.Actual},
+			{includeDerived, &r.Forecast, &r.Value.Forecast},
+			{includeAll, &r.Total, &r.Value.Total},
+		} {
+			power, _ := powerFunc(e.include, kind)
+			*e.energy, err = convert.IntegrateSteps(start, end, stepSize, power)
+			if err != nil && !errors.Is(err, convert.ErrNoData) {
+				ctx.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+			*e.value, err = valueOf(start, end, power)
+			if err != nil {
+				ctx.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+		}
+		reports = append(reports, r)
+		start = end
+	}
+
+	ctx.JSON(http.StatusOK, reports)
+}
@@ -0,0 +1,248 @@
+package production
+
+import (
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	models "github.com/theMomax/openefs/models/production"
+	"github.com/theMomax/openefs/utils/metadata"
+)
+
+// Series formats
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+)
+
+// maxSeriesLength limits the amount of points returned by a single request.
+const maxSeriesLength = 100000
+
+var errIllegalInterval = errors.New("the given interval is invalid")
+
+// point is a single entry of a production time-series.
+type point struct {
+	Time  time.Time `json:"time"`
+	Power *float64  `json:"power"`
+	// Curtailed is the power, that is expected to be produced, if the site's
+	// export limit is enforced. Power is the unconstrained potential.
+	Curtailed  *float64   `json:"curtailedPower"`
+	Derived    bool       `json:"derived"`
+	Model      *uint64    `json:"model"`
+	Issued     *time.Time `json:"issued"`
+	Source     string     `json:"source"`
+	Confidence string     `json:"confidence"`
+	// FeedInRevenue and AvoidedPurchase are the financial value of the
+	// energy produced during the point's step or interval.
+	FeedInRevenue   *float64 `json:"feedInRevenue"`
+	AvoidedPurchase *float64 `json:"avoidedPurchase"`
+}
+
+func handleSeriesRequest(ctx *gin.Context) {
+	fromunixsecs, err := strconv.ParseInt(ctx.Param("from"), 10, 64)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	tounixsecs, err := strconv.ParseInt(ctx.Param("to"), 10, 64)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	loc, err := location(ctx)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	from := time.Unix(fromunixsecs, 0).In(loc)
+	to := time.Unix(tounixsecs, 0).In(loc)
+	if from.After(to) {
+		ctx.AbortWithError(http.StatusBadRequest, errors.New("from must not be after to")).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	interval := stepSize
+	if i, ok := ctx.GetQuery("interval"); ok {
+		interval, err = time.ParseDuration(i)
+		if err != nil {
+			ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+			return
+		}
+	}
+	if interval <= 0 || to.Sub(from)/interval > maxSeriesLength {
+		ctx.AbortWithError(http.StatusBadRequest, errIllegalInterval).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	array, err := arrayOf(ctx)
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	var points []point
+	if interval == stepSize {
+		points = series(array, models.Round(from), to)
+	} else {
+		points = resample(array, from, to, interval)
+	}
+
+	switch ctx.DefaultQuery("format", formatJSON) {
+	case formatJSON:
+		ctx.JSON(http.StatusOK, points)
+	case formatCSV:
+		writeCSV(ctx, points, loc)
+	default:
+		ctx.AbortWithError(http.StatusBadRequest, errors.New("format must be one of: "+formatJSON+", "+formatCSV)).SetType(gin.ErrorTypeBind)
+	}
+}
+
+// series returns a point of the given array for each step from start to end.
+func series(array string, start, end time.Time) []point {
+	if end.Before(start) {
+		return []point{}
+	}
+	points := make([]point, 0, end.Sub(start)/stepSize+1)
+	for t := start; !t.After(end); t = t.Add(stepSize) {
+		points = append(points, at(array, t))
+	}
+	return points
+}
+
+// at returns the point describing the given array's step at t. The financial
+// value is only provided for the whole site.
+func at(array string, t time.Time) point {
+	p := point{
+		Time: t,
+	}
+	u := updateOf(array, t)
+	if u == nil || u.Data() == nil {
+		return p
+	}
+	potential, curtailed := power(u, powerPotential), power(u, powerCurtailed)
+	if array != "" {
+		curtailed = potential * curtailment(t)
+	}
+	prov := models.ProvenanceOf(u)
+	p.Power = &potential
+	p.Curtailed = &curtailed
+	p.Derived = u.IsDerived()
+	if prov.Model != nil {
+		id := prov.Model.ID()
+		p.Model = &id
+	}
+	if !prov.Issued.IsZero() {
+		issued := prov.Issued.In(t.Location())
+		p.Issued = &issued
+	}
+	p.Source = prov.Source
+	p.Confidence = prov.Confidence.String()
+	if array == "" {
+		setValue(&p, t.Add(-stepSize/2), t.Add(stepSize/2))
+	}
+	return p
+}
+
+// setValue sets p's financial value to that of the energy expected to be
+// produced from one point in time to another, if the export limit is enforced.
+func setValue(p *point, from, to time.Time) {
+	power, _ := powerFunc(includeAll, powerCurtailed)
+	if v, err := valueOf(from, to, power); err == nil && v != nil {
+		p.FeedInRevenue = &v.FeedInRevenue
+		p.AvoidedPurchase = &v.AvoidedPurchase
+	}
+}
+
+// resample returns a point of the given array for each interval starting at
+// from. The power of each point is the average power of all steps within its
+// interval.
+func resample(array string, from, to time.Time, interval time.Duration) []point {
+	points := make([]point, 0, to.Sub(from)/interval+1)
+	for b := from; !b.After(to); b = b.Add(interval) {
+		// all steps within the interval, or the step covering the interval's
+		// start, if the interval is shorter than a step
+		first := models.Round(b)
+		if first.Before(b) {
+			first = first.Add(stepSize)
+		}
+		steps := series(array, first, b.Add(interval-1))
+		if len(steps) == 0 {
+			steps = []point{at(array, models.Round(b))}
+		}
+		m := merge(b, steps)
+		if m.Power != nil && array == "" {
+			setValue(&m, b, b.Add(interval))
+		}
+		points = append(points, m)
+	}
+	return points
+}
+
+// merge combines the given points into a single one at time t.
+func merge(t time.Time, points []point) point {
+	m := point{
+		Time: t,
+	}
+	sum, curtailed, count := 0.0, 0.0, 0
+	for _, p := range points {
+		if p.Power == nil {
+			continue
+		}
+		sum += *p.Power
+		curtailed += *p.Curtailed
+		count++
+		m.Derived = m.Derived || p.Derived
+		if p.Model != nil && (m.Model == nil || *p.Model > *m.Model) {
+			m.Model = p.Model
+		}
+		if p.Issued != nil && (m.Issued == nil || p.Issued.After(*m.Issued)) {
+			m.Issued = p.Issued
+		}
+		if m.Source == "" {
+			m.Source = p.Source
+		} else if m.Source != p.Source {
+			m.Source = "mixed"
+		}
+		if m.Confidence == "" || p.Confidence != metadata.High.String() {
+			m.Confidence = p.Confidence
+		}
+	}
+	if count > 0 {
+		avg, avgCurtailed := sum/float64(count), curtailed/float64(count)
+		m.Power = &avg
+		m.Curtailed = &avgCurtailed
+	}
+	return m
+}
+
+func writeCSV(ctx *gin.Context, points []point, loc *time.Location) {
+	ctx.Status(http.StatusOK)
+	ctx.Header("Content-Type", "text/csv")
+	w := csv.NewWriter(ctx.Writer)
+	w.Write([]string{"time", "power", "curtailedPower", "derived", "model", "issued", "source", "confidence", "feedInRevenue", "avoidedPurchase"})
+	for _, p := range points {
+		record := []string{p.Time.In(loc).Format(time.RFC3339), "", "", strconv.FormatBool(p.Derived), "", "", p.Source, p.Confidence, "", ""}
+		if p.Power != nil {
+			record[1] = strconv.FormatFloat(*p.Power, 'f', -1, 64)
+			record[2] = strconv.FormatFloat(*p.Curtailed, 'f', -1, 64)
+		}
+		if p.Model != nil {
+			record[4] = strconv.FormatUint(*p.Model, 10)
+		}
+		if p.Issued != nil {
+			record[5] = p.Issued.In(loc).Format(time.RFC3339)
+		}
+		if p.FeedInRevenue != nil {
+			record[8] = strconv.FormatFloat(*p.FeedInRevenue, 'f', -1, 64)
+			record[9] = strconv.FormatFloat(*p.AvoidedPurchase, 'f', -1, 64)
+		}
+		w.Write(record)
+	}
+	w.Flush()
+}
@@ -0,0 +1,54 @@
+package production
+
+import (
+	"errors"
+	"time"
+
+	"github.com/theMomax/openefs/cache/price"
+	"github.com/theMomax/openefs/utils/convert"
+)
+
+// value is the financial value of the energy produced during some period.
+type value struct {
+	// FeedInRevenue is the revenue, if all energy was fed into the grid.
+	FeedInRevenue float64 `json:"feedInRevenue"`
+	// AvoidedPurchase is the cost saved, if all energy was consumed on site.
+	AvoidedPurchase float64 `json:"avoidedPurchase"`
+	// Coverage is the fraction (0 to 1) of the period, for which power and
+	// prices were available.
+	Coverage float64 `json:"coverage"`
+}
+
+// valueOf returns the value of the energy provided by power from one point in
+// time to another. It returns nil if there are no prices for the period.
+func valueOf(from, to time.Time, power func(time.Time) *float64) (*value, error) {
+	priced := func(p func(*price.Data) float64) func(time.Time) *float64 {
+		return func(t time.Time) *float64 {
+			w := power(t)
+			d := price.Get(t)
+			if w == nil || d == nil {
+				return nil
+			}
+			v := *w * p(d)
+			return &v
+		}
+	}
+
+	revenue, err := convert.IntegrateSteps(from, to, stepSize, priced(func(d *price.Data) float64 { return d.Export }))
+	if errors.Is(err, convert.ErrNoData) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	avoided, err := convert.IntegrateSteps(from, to, stepSize, priced(func(d *price.Data) float64 { return d.Import }))
+	if err != nil {
+		return nil, err
+	}
+
+	// the integrals' unit is kWh times the prices' currency per kWh
+	return &value{
+		FeedInRevenue:   revenue.KWh,
+		AvoidedPurchase: avoided.KWh,
+		Coverage:        revenue.Coverage,
+	}, nil
+}
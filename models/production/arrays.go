@@ -0,0 +1,261 @@
+package production
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/theMomax/openefs/config"
+	"github.com/theMomax/openefs/utils/metadata"
+	"github.com/theMomax/openefs/utils/solar"
+	timeutils "github.com/theMomax/openefs/utils/time"
+)
+
+// Config paths
+const (
+	PathArrays        = "models.production.arrays"
+	PathArraysTimeout = "models.production.timeout.arrays"
+)
+
+// reasonIncomplete is attached to the metadata of the site's measured values,
+// for which not all arrays reported.
+const reasonIncomplete = "incomplete: not all arrays reported production"
+
+func init() {
+	config.RootCtx.PersistentFlags().StringSlice(PathArrays, []string{}, "the site's PV-arrays, that report production separately, each given as <id>:<tilt>:<azimuth>:<peakpower> (e.g. east:30:90:3000); the site's location and albedo apply to all arrays")
+	config.Viper.BindPFlag(PathArrays, config.RootCtx.PersistentFlags().Lookup(PathArrays))
+
+	config.RootCtx.PersistentFlags().Duration(PathArraysTimeout, 10*time.Minute, "the duration after the first array reported a step, after which the partial sum of the reported arrays is used as the site's (incomplete) production")
+	config.Viper.BindPFlag(PathArraysTimeout, config.RootCtx.PersistentFlags().Lookup(PathArraysTimeout))
+
+	config.OnInitialize(func() {
+		arraysTimeout = config.Viper.GetDuration(PathArraysTimeout)
+		if arraysTimeout < 0 {
+			config.InvalidConfiguration(PathArraysTimeout, "[0, +inf)")
+		}
+		arrays = make([]Array, 0)
+		arraySeries = make(map[string]*series)
+		for _, s := range config.Viper.GetStringSlice(PathArrays) {
+			a, ok := parseArray(s)
+			if !ok || arraySeries[a.ID] != nil {
+				config.InvalidConfiguration(PathArrays, "a list of unique <id>:<tilt>:<azimuth>:<peakpower> with tilt in [0, 90], azimuth in [0, 360) and peakpower in (0, +inf)")
+			}
+			arrays = append(arrays, a)
+			arraySeries[a.ID] = newSeries(&arrays[len(arrays)-1])
+		}
+	})
+}
+
+// Array describes a PV-array, that reports its production separately. Its
+// location is the site's location.
+type Array struct {
+	ID        string  `json:"id"`
+	Tilt      float64 `json:"tilt"`
+	Azimuth   float64 `json:"azimuth"`
+	PeakPower float64 `json:"peakPower"`
+}
+
+// Plane returns the plane describing the array's location and orientation.
+func (a Array) Plane() solar.Plane {
+	p := site
+	p.Tilt = a.Tilt
+	p.Azimuth = a.Azimuth
+	return p
+}
+
+var (
+	arrays        []Array
+	arraysTimeout time.Duration
+)
+
+// pendingStep holds the measurements of the arrays, that reported for a
+// single step.
+type pendingStep struct {
+	measurements map[string]Update
+	// since is the time the first array reported at
+	since time.Time
+	// incomplete is true, once the partial sum was fed into the site's series
+	incomplete bool
+}
+
+// pendingArrays holds the measurements of each step, until all arrays have
+// reported. It is only accessed by the goroutine owning the cache.
+var pendingArrays = make(map[time.Time]*pendingStep)
+
+var arraySubscribers = make(map[int64]func(Update), 0)
+
+// Arrays returns the site's PV-arrays. It is empty, if production is reported
+// for the whole site only.
+func Arrays() []Array {
+	a := make([]Array, len(arrays))
+	copy(a, arrays)
+	return a
+}
+
+// ArrayByID returns the array with the given id.
+func ArrayByID(id string) (Array, bool) {
+	for _, a := range arrays {
+		if a.ID == id {
+			return a, true
+		}
+	}
+	return Array{}, false
+}
+
+// SubscribeArrays registers a callback to be called for each measured or
+// predicted value of a single array. The array's id is contained in the
+// update's Data. It returns the id required for unsubscribing. It returns -1,
+// if callback is nil.
+func SubscribeArrays(callback func(Update)) int64 {
+	if callback == nil {
+		return -1
+	}
+
+	id := rand.Int63()
+
+	sm.Lock()
+	arraySubscribers[id] = callback
+	sm.Unlock()
+	return id
+}
+
+// UnsubscribeArrays unsubscribes the callback with the given id.
+func UnsubscribeArrays(id int64) {
+	sm.Lock()
+	delete(arraySubscribers, id)
+	sm.Unlock()
+}
+
+func notifyArrays(update Update) {
+	sm.RLock()
+	for _, s := range arraySubscribers {
+		go s(update)
+	}
+	sm.RUnlock()
+}
+
+// handleArrayUpdate feeds the measurement of a single array into the array's
+// series. As soon as all arrays have reported for a step, their sum is fed
+// into the site's series.
+func handleArrayUpdate(u Update) {
+	r := Round(u.Time())
+	for t := range pendingArrays {
+		if r.Sub(t) >= outdated {
+			delete(pendingArrays, t)
+		}
+	}
+	p := pendingArrays[r]
+	if p == nil {
+		p = &pendingStep{
+			measurements: make(map[string]Update),
+			since:        timeutils.Now(),
+		}
+		pendingArrays[r] = p
+	}
+	p.measurements[u.Data().Array] = copyOf(u)
+
+	within(arraySeries[u.Data().Array], func() {
+		u.Data().Power = normalize(u.Data().Power, u.Time())
+		handleProductionUpdate(u)
+	})
+	sumForecasts()
+
+	if len(p.measurements) == len(arrays) {
+		delete(pendingArrays, r)
+		sumMeasurements(r, p.measurements, false)
+	}
+	sumPendingArrays()
+}
+
+// sumPendingArrays feeds the partial sum of each step, for which not all
+// arrays reported within the timeout, into the site's series. The sum is
+// replaced as soon as the remaining arrays report. Timeouts are checked
+// whenever an update is received and periodically by the ingestion-stage.
+func sumPendingArrays() {
+	now := timeutils.Now()
+	for t, p := range pendingArrays {
+		if p.incomplete || now.Sub(p.since) < arraysTimeout {
+			continue
+		}
+		p.incomplete = true
+		missing := make([]string, 0, len(arrays))
+		for _, a := range arrays {
+			if p.measurements[a.ID] == nil {
+				missing = append(missing, a.ID)
+			}
+		}
+		log.WithField("time", t).WithField("missing", missing).Warn("arrays did not report production in time, using partial sum")
+		sumMeasurements(t, p.measurements, true)
+	}
+}
+
+// sumMeasurements feeds the sum of the arrays' measurements at t into the
+// site's series. An incomplete sum is only a lower bound of the site's
+// production, thus it is marked as being of low confidence and censored.
+func sumMeasurements(t time.Time, measurements map[string]Update, incomplete bool) {
+	sum := &Data{}
+	var meta metadata.Metadata
+	for _, a := range measurements {
+		sum.Power += a.Data().Power
+		sum.Curtailed = sum.Curtailed || a.Data().Curtailed
+		if meta == nil || a.Meta().ID() > meta.ID() {
+			meta = a.Meta()
+		}
+	}
+	if incomplete {
+		meta = &metadata.Annotated{
+			Metadata: meta,
+			Quality:  metadata.Low,
+			Reason:   reasonIncomplete,
+		}
+	}
+
+	log.WithField("time", t).WithField("value", sum.Power).WithField("incomplete", incomplete).Debug("summed arrays' production")
+	within(siteSeries, func() {
+		sum.Power = normalize(sum.Power, t)
+		handleProductionUpdate(&update{
+			data:       sum,
+			time:       t,
+			meta:       meta,
+			incomplete: incomplete,
+		})
+	})
+}
+
+// IsIncomplete returns true if u is the site's measured value, for which not
+// all arrays reported.
+func IsIncomplete(u Update) bool {
+	v, ok := u.(*update)
+	return ok && v.incomplete
+}
+
+// isArrayMeasurement returns true if u is the measurement of a single array.
+func isArrayMeasurement(u Update) bool {
+	return u.Data() != nil && u.Data().Array != ""
+}
+
+func parseArray(s string) (Array, bool) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 4 || parts[0] == "" {
+		return Array{}, false
+	}
+	values := make([]float64, 3)
+	for i, p := range parts[1:] {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return Array{}, false
+		}
+		values[i] = v
+	}
+	a := Array{
+		ID:        parts[0],
+		Tilt:      values[0],
+		Azimuth:   values[1],
+		PeakPower: values[2],
+	}
+	if a.Tilt < 0 || a.Tilt > 90 || a.Azimuth < 0 || a.Azimuth >= 360 || a.PeakPower <= 0 {
+		return Array{}, false
+	}
+	return a, true
+}
@@ -30,8 +30,16 @@ type element struct {
 	derived    *numbers.Average
 	nonderived *numbers.Average
 	m          *sync.Mutex
-	daysAhead  uint
-	hourOfDay  uint
+	// array is empty for the whole site
+	array     string
+	daysAhead uint
+	stepOfDay uint
+}
+
+// avgkey is the hash of an element.
+type avgkey struct {
+	array string
+	step  uint
 }
 
 // TODO: rewrite this into an instance that can be used by cache and this package
@@ -44,30 +52,40 @@ var avgcache *generic.Cache
 
 // RunAverage initializes the caching package.
 func RunAverage() {
-	Subscribe(func(u Update) {
-		dist := Round(u.Time()).Sub(Round(timeutils.Now()))
-		daysAhead := uint(dist.Truncate(24*time.Hour) / (24 * time.Hour))
-		hourOfDay := uint(u.Time().Hour())
-
-		v, ok := avgcache.Get(daysAhead*24 + hourOfDay).(*element)
-		if !ok {
-			v = &element{
-				derived:    numbers.NewAverageSum(halfLife),
-				nonderived: numbers.NewAverageSum(halfLife),
-				m:          &sync.Mutex{},
-				daysAhead:  daysAhead,
-				hourOfDay:  hourOfDay,
-			}
-		}
-		v.m.Lock()
-		defer v.m.Unlock()
-		if u.IsDerived() {
-			v.derived.Apply(u.Data().Power)
-		} else {
-			v.nonderived.Apply(u.Data().Power)
+	Subscribe(average)
+	SubscribeArrays(average)
+}
+
+// average applies the denormalized update u to the average day of the site or
+// of u's array.
+func average(u Update) {
+	// a partial sum would bias the site's average day
+	if IsIncomplete(u) {
+		return
+	}
+	array := u.Data().Array
+	daysAhead := DaysAhead(u.Time())
+	stepOfDay := StepOfDay(u.Time())
+
+	v, ok := avgcache.Get(hash(array, daysAhead, stepOfDay)).(*element)
+	if !ok {
+		v = &element{
+			derived:    numbers.NewAverageSum(halfLife),
+			nonderived: numbers.NewAverageSum(halfLife),
+			m:          &sync.Mutex{},
+			array:      array,
+			daysAhead:  daysAhead,
+			stepOfDay:  stepOfDay,
 		}
-		avgcache.Update(v)
-	})
+	}
+	v.m.Lock()
+	defer v.m.Unlock()
+	if u.IsDerived() {
+		v.derived.Apply(u.Data().Power)
+	} else {
+		v.nonderived.Apply(u.Data().Power)
+	}
+	avgcache.Update(v)
 }
 
 func avgoutdated(at interface{}) bool {
@@ -75,16 +93,21 @@ func avgoutdated(at interface{}) bool {
 }
 
 func (e *element) Time() time.Time {
-	return timeutils.Now().Add(time.Duration(e.daysAhead) * 24 * time.Hour).Add(time.Duration(e.hourOfDay) * time.Hour)
+	return timeutils.Now().Add(time.Duration(e.daysAhead) * 24 * time.Hour).Add(time.Duration(e.stepOfDay) * config.Viper.GetDuration(PathStepSize))
 }
 
 func (e *element) Hash() interface{} {
-	return e.daysAhead*24 + e.hourOfDay
+	return hash(e.array, e.daysAhead, e.stepOfDay)
+}
+
+func hash(array string, daysAhead, stepOfDay uint) avgkey {
+	return avgkey{array, daysAhead*StepsPerDay() + stepOfDay}
 }
 
-// GetDerived returns the average derived power for time t.
-func GetDerived(daysAhead, hourOfDay uint) (val float64, ok bool) {
-	v, ok := avgcache.Get(daysAhead*24 + hourOfDay).(*element)
+// GetDerived returns the average derived power for the given step of the day
+// daysAhead days in the future.
+func GetDerived(daysAhead, stepOfDay uint) (val float64, ok bool) {
+	v, ok := avgcache.Get(hash("", daysAhead, stepOfDay)).(*element)
 	if !ok {
 		return 0.0, false
 	}
@@ -93,9 +116,20 @@ func GetDerived(daysAhead, hourOfDay uint) (val float64, ok bool) {
 	return v.derived.Get(), true
 }
 
-// GetNonDerived returns the average non-derived power for time t.
-func GetNonDerived(daysAhead, hourOfDay uint) (val float64, ok bool) {
-	v, ok := avgcache.Get(daysAhead*24 + hourOfDay).(*element)
+// GetNonDerived returns the average non-derived power for the given step of
+// the day daysAhead days in the future.
+func GetNonDerived(daysAhead, stepOfDay uint) (val float64, ok bool) {
+	return nonDerived("", daysAhead, stepOfDay)
+}
+
+// averageNonDerived returns the current series' average non-derived power for
+// the step of the day t belongs to.
+func averageNonDerived(t time.Time) (val float64, ok bool) {
+	return nonDerived(current.id(), 0, StepOfDay(t))
+}
+
+func nonDerived(array string, daysAhead, stepOfDay uint) (val float64, ok bool) {
+	v, ok := avgcache.Get(hash(array, daysAhead, stepOfDay)).(*element)
 	if !ok {
 		return 0.0, false
 	}
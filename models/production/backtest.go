@@ -0,0 +1,306 @@
+package production
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/theMomax/openefs/models/production/weather"
+	"github.com/theMomax/openefs/utils/metadata"
+)
+
+// backtestScript trains a fresh production-model and predicts the test-samples.
+const backtestScript = "backtest_production.py"
+
+// Record is a historical step of the whole site.
+type Record struct {
+	Time      time.Time
+	Power     float64
+	Curtailed bool
+	Weather   weather.Data
+}
+
+// ReadRecords reads Records from CSV. The first row names the columns: time
+// (unix-seconds), production (W), optionally curtailed and any of the
+// weather-fields named as in weather.Data. Missing weather-fields are zero.
+func ReadRecords(r io.Reader) ([]Record, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("missing header")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[name] = i
+	}
+	for _, name := range []string{"time", "production"} {
+		if _, ok := columns[name]; !ok {
+			return nil, errors.New("missing column " + name)
+		}
+	}
+	// the weather-fields by column
+	fields := make(map[int]int)
+	wt := reflect.TypeOf(weather.Data{})
+	for f := 0; f < wt.NumField(); f++ {
+		if i, ok := columns[wt.Field(f).Tag.Get("csv")]; ok {
+			fields[i] = f
+		}
+	}
+
+	records := make([]Record, 0, len(rows)-1)
+	for n, row := range rows[1:] {
+		line := strconv.Itoa(n + 2)
+		var rec Record
+		unixsecs, err := strconv.ParseInt(row[columns["time"]], 10, 64)
+		if err != nil {
+			return nil, errors.New("line " + line + ": invalid time: " + err.Error())
+		}
+		rec.Time = time.Unix(unixsecs, 0)
+		if rec.Power, err = strconv.ParseFloat(row[columns["production"]], 64); err != nil {
+			return nil, errors.New("line " + line + ": invalid production: " + err.Error())
+		}
+		if i, ok := columns["curtailed"]; ok && row[i] != "" {
+			if rec.Curtailed, err = strconv.ParseBool(row[i]); err != nil {
+				return nil, errors.New("line " + line + ": invalid curtailed: " + err.Error())
+			}
+		}
+		wv := reflect.ValueOf(&rec.Weather).Elem()
+		for i, f := range fields {
+			if row[i] == "" {
+				continue
+			}
+			v, err := strconv.ParseFloat(row[i], 64)
+			if err != nil {
+				return nil, errors.New("line " + line + ": invalid " + rows[0][i] + ": " + err.Error())
+			}
+			wv.Field(f).SetFloat(v)
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// Result is the outcome of a backtest. The errors are given in W.
+type Result struct {
+	Folds uint `json:"folds"`
+	// Samples is the amount of predicted steps, whose error was measured.
+	// Censored steps are excluded.
+	Samples int     `json:"samples"`
+	MAE     float64 `json:"mae"`
+	RMSE    float64 `json:"rmse"`
+}
+
+// Backtest evaluates the configured production-model on records using
+// walk-forward validation (see split). For each fold, a fresh model is trained
+// on the fold's training-samples only and predicts the fold's tests.
+//
+// Backtest replaces this package's cache, thus the pipeline must not be
+// running.
+func Backtest(records []Record, folds uint) (Result, error) {
+	result := Result{Folds: folds}
+	if folds == 0 {
+		return result, errors.New("at least one fold is required")
+	}
+	samples := replay(records)
+	if len(samples) < int(folds)+1 {
+		return result, errors.New("not enough samples for " + strconv.Itoa(int(folds)) + " folds: " + strconv.Itoa(len(samples)))
+	}
+
+	// the production-model is never touched
+	dir, err := ioutil.TempDir("", "openefs-backtest")
+	if err != nil {
+		return result, err
+	}
+	defer os.RemoveAll(dir)
+	production := modelPath
+	defer func() { modelPath = production }()
+
+	var absolute, squared float64
+	for k, f := range split(samples, folds) {
+		if len(f.training) == 0 {
+			return result, errors.New("no training-samples precede the tests of fold " + strconv.Itoa(k+1))
+		}
+		modelPath = filepath.Join(dir, strconv.Itoa(k+1), filepath.Base(production))
+		if err := createModel(modelPath); err != nil {
+			return result, err
+		}
+		log.WithField("fold", k+1).WithField("training", len(f.training)).WithField("tests", len(f.tests)).Debug("running fold...")
+		res, out, err := call(context.Background(), trainingTimeout, backtestScript, modelRequest{
+			Samples: plain(f.training),
+			Tests:   plain(f.tests),
+			Spec:    &spec,
+		})
+		if err != nil {
+			log.WithField("out", string(out)).Trace("backtest failed")
+			return result, err
+		}
+		if len(res.Output) != len(f.tests)*int(outputSteps) {
+			return result, errors.New("unexpected amount of predictions: " + strconv.Itoa(len(res.Output)))
+		}
+		for i, s := range f.tests {
+			for o := range s.Targets {
+				if s.Censored[o] {
+					continue
+				}
+				e := res.Output[i*int(outputSteps)+o]*s.scale[o] - s.power[o]
+				absolute += math.Abs(e)
+				squared += e * e
+				result.Samples++
+			}
+		}
+	}
+	if result.Samples == 0 {
+		return result, errors.New("all tested steps are censored")
+	}
+	result.MAE = absolute / float64(result.Samples)
+	result.RMSE = math.Sqrt(squared / float64(result.Samples))
+	return result, nil
+}
+
+// fold is one step of a walk-forward validation.
+type fold struct {
+	training []backtestSample
+	tests    []backtestSample
+}
+
+// split splits the chronologically ordered samples into folds+1 consecutive
+// blocks. The k-th fold tests the (k+1)-th block. Its training-samples are the
+// preceding samples, whose targets were all measured when the first test is
+// predicted, i.e. at the step preceding its first target.
+func split(samples []backtestSample, folds uint) []fold {
+	split := make([]fold, 0, folds)
+	block := len(samples) / (int(folds) + 1)
+	for k := 1; k <= int(folds); k++ {
+		from, to := k*block, (k+1)*block
+		if k == int(folds) {
+			to = len(samples)
+		}
+		f := fold{tests: samples[from:to]}
+		for _, s := range samples[:from] {
+			if s.last().Before(f.tests[0].time) {
+				f.training = append(f.training, s)
+			}
+		}
+		split = append(split, f)
+	}
+	return split
+}
+
+// backtestSample is a training-sample together with what is required for
+// measuring the error of its prediction.
+type backtestSample struct {
+	sample
+	// power is the measured power (in W) of each target
+	power []float64
+	// scale is the factor, by which each target's prediction is
+	// denormalized. It is recorded together with the sample's inputs.
+	scale []float64
+	// time is the time of the first target
+	time time.Time
+}
+
+// last returns the time of s's last target.
+func (s backtestSample) last() time.Time {
+	return s.time.Add(time.Duration(len(s.Targets)-1) * stepsize)
+}
+
+// replay fills the cache with records in chronological order and returns all
+// training-samples, that can be derived from them, in chronological order. The
+// average day is updated after each record. Each value is normalized and each
+// sample's inputs are built as soon as the required records are replayed, so
+// that they are as they would have been at that time. The weather is known in
+// advance, as it is forecasted.
+func replay(records []Record) []backtestSample {
+	sorted := make([]Record, len(records))
+	copy(sorted, records)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+
+	cache = make(map[time.Time]*cupdate)
+	history = make(map[time.Time]*cupdate)
+	for i, rec := range sorted {
+		t := Round(rec.Time)
+		data := rec.Weather
+		cache[t] = &cupdate{
+			w: &weatherUpdate{data: &data, time: t, meta: &metadata.Basic{Timestamp: rec.Time, Identifier: uint64(i + 1)}},
+		}
+	}
+
+	// the samples, whose inputs are built, by their first target
+	pending := make(map[time.Time]*backtestSample)
+	power := make(map[time.Time]float64, len(sorted))
+	censored := make(map[time.Time]bool, len(sorted))
+	samples := make([]backtestSample, 0, len(sorted))
+	for n, rec := range sorted {
+		t := Round(rec.Time)
+		meta := &metadata.Basic{Timestamp: rec.Time, Identifier: uint64(n + 1)}
+		cache[t].p = &update{data: &Data{Power: normalize(rec.Power, t), Curtailed: rec.Curtailed}, time: t, meta: meta}
+		power[t] = rec.Power
+		censored[t] = isCensored(cache[t])
+		average(&update{data: &Data{Power: rec.Power, Curtailed: rec.Curtailed}, time: t, meta: meta})
+
+		// the sample, whose targets are complete now
+		if i := t.Add(-time.Duration(outputSteps-1) * stepsize); pending[i] != nil {
+			s := pending[i]
+			delete(pending, i)
+			if allOf(i, t, isTrainable) {
+				for j := i; !j.After(t); j = j.Add(stepsize) {
+					s.Targets = append(s.Targets, cache[j].p.Data().Power)
+					s.Censored = append(s.Censored, censored[j])
+					s.power = append(s.power, power[j])
+				}
+				samples = append(samples, *s)
+			}
+		}
+
+		// the sample, that would be predicted now
+		i := t.Add(stepsize)
+		last := i.Add(time.Duration(outputSteps-1) * stepsize)
+		if strategy != direct {
+			last = t
+		}
+		if !allOf(i.Add(-time.Duration(requiredPreceding)*stepsize), t, isTrainable) || !allOf(i, last, weatherExists) {
+			continue
+		}
+		s, _ := newInputs(i, model)
+		b := &backtestSample{sample: s, time: i}
+		for o := uint(0); o < outputSteps; o++ {
+			b.scale = append(b.scale, denormalize(1, i.Add(time.Duration(o)*stepsize)))
+		}
+		pending[i] = b
+	}
+	return samples
+}
+
+// allOf returns true if condition holds for the cached steps from up to to
+// (inclusive).
+func allOf(from, to time.Time, condition func(*cupdate) bool) bool {
+	for j := from; !j.After(to); j = j.Add(stepsize) {
+		if !condition(cache[j]) {
+			return false
+		}
+	}
+	return true
+}
+
+// plain returns the training-samples of samples.
+func plain(samples []backtestSample) []sample {
+	p := make([]sample, len(samples))
+	for i, s := range samples {
+		p[i] = s.sample
+	}
+	return p
+}
@@ -0,0 +1,88 @@
+package production
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/theMomax/openefs/models/production/weather"
+)
+
+func TestReadRecords(t *testing.T) {
+	cases := []struct {
+		name    string
+		csv     string
+		records []Record
+		err     string
+	}{
+		{
+			name: "minimal",
+			csv:  "time,production\n0,100\n3600,200.5\n",
+			records: []Record{
+				{Time: time.Unix(0, 0), Power: 100},
+				{Time: time.Unix(3600, 0), Power: 200.5},
+			},
+		},
+		{
+			name: "curtailed and weather in any order",
+			csv:  "cloudCover,production,unknown,curtailed,time,temperature\n0.5,1000,x,true,0,12\n,2000,y,,3600,\n",
+			records: []Record{
+				{Time: time.Unix(0, 0), Power: 1000, Curtailed: true, Weather: weather.Data{CloudCover: 0.5, Temperature: 12}},
+				{Time: time.Unix(3600, 0), Power: 2000},
+			},
+		},
+		{name: "header only", csv: "time,production\n", records: []Record{}},
+		{name: "empty", csv: "", err: "missing header"},
+		{name: "missing time", csv: "production\n100\n", err: "missing column time"},
+		{name: "missing production", csv: "time,cloudCover\n0,0.5\n", err: "missing column production"},
+		{name: "invalid time", csv: "time,production\n0,100\nnow,200\n", err: "line 3: invalid time"},
+		{name: "fractional time", csv: "time,production\n0.5,100\n", err: "line 2: invalid time"},
+		{name: "invalid production", csv: "time,production\n0,\n", err: "line 2: invalid production"},
+		{name: "invalid curtailed", csv: "time,production,curtailed\n0,100,maybe\n", err: "line 2: invalid curtailed"},
+		{name: "invalid weather", csv: "time,production,humidity\n0,100,wet\n", err: "line 2: invalid humidity"},
+		{name: "inconsistent columns", csv: "time,production\n0,100,1\n", err: "wrong number of fields"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			records, err := ReadRecords(strings.NewReader(c.csv))
+			if c.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), c.err)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.records, records)
+		})
+	}
+}
+
+func TestSplitTrainsOnPrecedingSamplesOnly(t *testing.T) {
+	start := time.Unix(0, 0)
+	samples := make([]backtestSample, 20)
+	for i := range samples {
+		samples[i] = backtestSample{
+			sample: sample{Targets: make([]float64, 3)},
+			time:   start.Add(time.Duration(i) * stepsize),
+		}
+	}
+
+	folds := split(samples, 3)
+	assert.Len(t, folds, 3)
+	tested := 0
+	for k, f := range folds {
+		if !assert.NotEmpty(t, f.tests) || !assert.NotEmpty(t, f.training, "fold %d", k+1) {
+			continue
+		}
+		tested += len(f.tests)
+		// the first test is predicted one step before its first target
+		predicted := f.tests[0].time.Add(-stepsize)
+		for _, s := range f.training {
+			assert.False(t, s.last().After(predicted), "fold %d trains on %v, which is measured after %v", k+1, s.last(), predicted)
+		}
+		// the samples, whose targets overlap the tests, are excluded
+		assert.Len(t, f.training, (k+1)*5-2)
+	}
+	assert.Equal(t, 15, tested)
+}
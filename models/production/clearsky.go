@@ -0,0 +1,100 @@
+package production
+
+import (
+	"time"
+
+	"github.com/theMomax/openefs/models/production/weather"
+	"github.com/theMomax/openefs/utils/metadata"
+	"github.com/theMomax/openefs/utils/solar"
+	timeutils "github.com/theMomax/openefs/utils/time"
+)
+
+// clearSkySamples is the amount of samples used for approximating the average
+// clear-sky power over a single time-step.
+const clearSkySamples = 12
+
+// clearSkyThreshold is the fraction of the peak-power below which the
+// clear-sky power is considered to be zero. This prevents normalization from
+// exploding around sunrise and sunset.
+const clearSkyThreshold = 0.01
+
+// ClearSkyPower returns the average power (in Watts) the site would produce
+// under a cloudless sky during the time-step at t.
+func ClearSkyPower(t time.Time) float64 {
+	return clearSkyPower(site, maximumProductionPower, t)
+}
+
+// clearSkyPower returns the average power (in Watts) the given plane with the
+// given peak-power would produce under a cloudless sky during the time-step at
+// t.
+func clearSkyPower(plane solar.Plane, peak float64, t time.Time) float64 {
+	if stepsize == 0 {
+		return plane.ClearSkyPower(t, peak)
+	}
+	start := Round(t).Add(-stepsize / 2)
+	sum := 0.0
+	for i := 0; i < clearSkySamples; i++ {
+		sum += plane.ClearSkyPower(start.Add(stepsize*time.Duration(2*i+1)/(2*clearSkySamples)), peak)
+	}
+	p := sum / clearSkySamples
+	if p < clearSkyThreshold*peak {
+		return 0
+	}
+	return p
+}
+
+// ClearSkyForecast returns the power (in Watts) the site is expected to
+// produce during the time-step at t, if the sky is covered as described by w.
+func ClearSkyForecast(t time.Time, w *weather.Data) float64 {
+	return siteSeries.clearSkyForecast(t, w)
+}
+
+func normalizeByClearSky(p float64, t time.Time) float64 {
+	n := current.clearSkyPower(t)
+	if n == 0 {
+		return 0
+	}
+	return p / n
+}
+
+func denormalizeByClearSky(p float64, t time.Time) float64 {
+	return p * current.clearSkyPower(t)
+}
+
+// inferenceClearSky predicts the given amount of steps starting at t using the
+// physical clear-sky model instead of the production-model.
+func inferenceClearSky(t time.Time, steps uint) {
+	clearSkyInference(t, steps, metadata.High, "")
+}
+
+// clearSkyInference predicts the given amount of steps of the current series
+// starting at t using the physical clear-sky model. Values of a confidence
+// other than high are annotated with the given reason.
+func clearSkyInference(t time.Time, steps uint, confidence metadata.Confidence, reason string) {
+	log.WithField("time", t).WithField("steps", steps).Debug("starting clear-sky inference...")
+	for i := uint(0); i < steps; i++ {
+		t := t.Add(time.Duration(i) * stepsize)
+		c := cache[t]
+		m := latest(model, c.w.Meta())
+		if confidence != metadata.High {
+			m = &metadata.Annotated{
+				Metadata: m,
+				Quality:  confidence,
+				Reason:   reason,
+			}
+		}
+		c.p = &update{
+			data: &Data{
+				Power: normalize(current.clearSkyForecast(t, c.w.Data()), t),
+			},
+			time:    t,
+			meta:    m,
+			derived: true,
+			source:  SourceClearSky,
+			model:   model,
+			issued:  timeutils.Now(),
+		}
+		log.WithField("id", c.p.Meta().ID()).WithField("time", t).WithField("value", c.p.Data().Power).Trace("sending update into outgoing channel")
+		emit(c.p)
+	}
+}
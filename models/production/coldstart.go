@@ -0,0 +1,124 @@
+package production
+
+import (
+	"time"
+
+	"github.com/theMomax/openefs/config"
+	"github.com/theMomax/openefs/utils/metadata"
+)
+
+// Config paths
+const (
+	PathColdStart = "models.production.coldstart"
+)
+
+// Cold-start methods
+const (
+	off = "off"
+)
+
+// reasonColdStart is attached to the metadata of values, that were predicted
+// without a complete production-history.
+const reasonColdStart = "cold-start: predicted without complete production-history"
+
+func init() {
+	config.RootCtx.PersistentFlags().String(PathColdStart, off, "the method used for predicting steps, that lack the required production-history (one of: "+off+", "+clearsky+" (predict from weather and time using the physical model), "+averageday+" (impute the missing history from the average day))")
+	config.Viper.BindPFlag(PathColdStart, config.RootCtx.PersistentFlags().Lookup(PathColdStart))
+
+	config.OnInitialize(func() {
+		coldStartMethod = config.Viper.GetString(PathColdStart)
+		switch coldStartMethod {
+		case off, averageday:
+		case clearsky:
+			// maximumProductionPower may not be initialized yet
+			if config.Viper.GetFloat64(PathMaximumProductionPower) <= 0 {
+				config.InvalidConfiguration(PathMaximumProductionPower, "(0, +inf) W")
+			}
+		default:
+			config.InvalidConfiguration(PathColdStart, off+", "+clearsky+", "+averageday)
+		}
+	})
+}
+
+var coldStartMethod string
+
+// coldStartableSteps returns the amount of steps starting at timestamps[i],
+// that are to be predicted, but lack the production-history required for
+// regular inference, and can be predicted using the configured cold-start
+// method.
+func coldStartableSteps(timestamps []time.Time, i int) uint {
+	c := cache[timestamps[i]]
+	if coldStartMethod == off || !toBePredicted(c) || !weatherExists(c) || (c.p != nil && !c.p.IsDerived()) || !withinHorizon(timestamps[i]) {
+		return 0
+	}
+
+	switch coldStartMethod {
+	case clearsky:
+		return 1
+	case averageday:
+		// the weather-value does exist for all required preceding steps, at
+		// least one production-value is missing, and there is no gap
+		if !isGapless(timestamps, i-int(requiredPreceding), i, stepsize) || !forAllIs(timestamps, func(t time.Time) bool {
+			return weatherExists(cache[t])
+		}, rng(i-int(requiredPreceding), i)...) || !existsIs(timestamps, func(t time.Time) bool {
+			return cache[t].p == nil
+		}, rng(i-int(requiredPreceding), i)...) {
+			return 0
+		}
+		if strategy == direct {
+			if isGapless(timestamps, i, i+int(outputSteps)-1, stepsize) && forAllIs(timestamps, func(t time.Time) bool {
+				return weatherExists(cache[t]) && (cache[t].p == nil || cache[t].p.IsDerived())
+			}, rng(i, i+int(outputSteps))...) {
+				return outputSteps
+			}
+			return 0
+		}
+		return subsequentSteps(timestamps, i)
+	}
+	return 0
+}
+
+// coldStart predicts the given amount of steps starting at t using the
+// configured cold-start method. All predicted values are marked as being of
+// low confidence.
+func coldStart(t time.Time, steps uint) {
+	switch coldStartMethod {
+	case clearsky:
+		clearSkyInference(t, steps, metadata.Low, reasonColdStart)
+	case averageday:
+		// impute the missing production-history temporarily
+		imputed := make([]time.Time, 0, requiredPreceding)
+		for i := t.Add(-1 * time.Duration(requiredPreceding) * stepsize); i.Sub(t) < 0; i = i.Add(stepsize) {
+			if cache[i].p != nil {
+				continue
+			}
+			power := 0.0
+			if v, ok := averageNonDerived(i); ok {
+				power = normalize(v, i)
+			}
+			cache[i].p = &update{
+				data: &Data{
+					Power: power,
+				},
+				time: i,
+				meta: &metadata.Annotated{
+					Metadata: latest(model, cache[i].w.Meta()),
+					Quality:  metadata.Low,
+					Reason:   reasonColdStart,
+				},
+				derived: true,
+			}
+			imputed = append(imputed, i)
+		}
+		predict(t, steps)
+		for _, i := range imputed {
+			cache[i].p = nil
+		}
+	}
+}
+
+// isColdStarted returns true, if the production-value was predicted using a
+// cold-start method.
+func isColdStarted(c *cupdate) bool {
+	return c != nil && c.p != nil && c.p.IsDerived() && metadata.ConfidenceOf(c.p.Meta()) == metadata.Low
+}
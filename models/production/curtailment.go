@@ -0,0 +1,94 @@
+package production
+
+import (
+	"math"
+
+	"github.com/theMomax/openefs/config"
+)
+
+// Config paths
+const (
+	PathSiteExportLimit     = "models.production.site.exportlimit"
+	PathSiteSelfConsumption = "models.production.site.selfconsumption"
+)
+
+// censoringTolerance is the fraction of the curtailment-threshold, by which a
+// measured value may fall short of the threshold and still be considered
+// clipped.
+const censoringTolerance = 0.01
+
+func init() {
+	config.RootCtx.PersistentFlags().Float64(PathSiteExportLimit, -1, "the maximum power (in W), that may be fed into the grid (e.g. 70% of the peak-power, 0 for zero-export rules or negative for no limit)")
+	config.Viper.BindPFlag(PathSiteExportLimit, config.RootCtx.PersistentFlags().Lookup(PathSiteExportLimit))
+
+	config.RootCtx.PersistentFlags().Float64(PathSiteSelfConsumption, 0, "the typical power (in W) consumed on site, while production exceeds the export limit")
+	config.Viper.BindPFlag(PathSiteSelfConsumption, config.RootCtx.PersistentFlags().Lookup(PathSiteSelfConsumption))
+
+	config.OnInitialize(func() {
+		exportLimit = config.Viper.GetFloat64(PathSiteExportLimit)
+		selfConsumption = config.Viper.GetFloat64(PathSiteSelfConsumption)
+		if selfConsumption < 0 {
+			config.InvalidConfiguration(PathSiteSelfConsumption, "[0, +inf) W")
+		}
+	})
+}
+
+var (
+	exportLimit     float64
+	selfConsumption float64
+)
+
+// ExportLimit returns the maximum power (in W), that may be fed into the grid.
+// It returns +Inf if there is no export limit.
+func ExportLimit() float64 {
+	if exportLimit < 0 {
+		return math.Inf(1)
+	}
+	return exportLimit
+}
+
+// CurtailmentThreshold returns the power (in W), above which production is
+// expected to be curtailed. It returns +Inf if there is no export limit.
+func CurtailmentThreshold() float64 {
+	return ExportLimit() + selfConsumption
+}
+
+// Curtail returns the power, that is expected to be produced, if the site's
+// export limit is enforced and the unconstrained potential is power.
+func Curtail(power float64) float64 {
+	return math.Min(power, CurtailmentThreshold())
+}
+
+// isCensored returns true if c's production-value is a measurement, that is
+// only a lower bound of the potential production. This is the case, if it was
+// clipped by curtailment or if it is the site's partial sum of the arrays.
+// Values are considered clipped, if they are flagged as curtailed or reach the
+// curtailment-threshold. The values of an array are considered clipped, if the
+// site's value is.
+func isCensored(c *cupdate) bool {
+	if c == nil || c.p == nil || c.p.IsDerived() || c.p.Data() == nil {
+		return false
+	}
+	if current.array != nil {
+		t := Round(c.p.Time())
+		clipped := false
+		within(siteSeries, func() {
+			clipped = isClipped(cache[t])
+		})
+		return c.p.Data().Curtailed || clipped
+	}
+	return IsIncomplete(c.p) || isClipped(c)
+}
+
+// isClipped returns true if c's measured production-value was clipped by
+// curtailment.
+func isClipped(c *cupdate) bool {
+	if c == nil || c.p == nil || c.p.IsDerived() || c.p.Data() == nil {
+		return false
+	}
+	if c.p.Data().Curtailed {
+		return true
+	}
+	threshold := CurtailmentThreshold()
+	return !math.IsInf(threshold, 1) && denormalize(c.p.Data().Power, c.p.Time()) >= threshold*(1-censoringTolerance)
+}
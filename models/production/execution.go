@@ -0,0 +1,197 @@
+package production
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/theMomax/openefs/config"
+	"github.com/theMomax/openefs/utils/breaker"
+	"github.com/theMomax/openefs/utils/metadata"
+	timeutils "github.com/theMomax/openefs/utils/time"
+)
+
+// Config paths
+const (
+	PathInferenceTimeout = "models.production.timeout.inference"
+	PathTrainingTimeout  = "models.production.timeout.training"
+	PathRetries          = "models.production.retries"
+	PathRetryBackoff     = "models.production.retrybackoff"
+	PathBreakerThreshold = "models.production.breaker.threshold"
+	PathBreakerCooldown  = "models.production.breaker.cooldown"
+	PathFallback         = "models.production.fallback"
+)
+
+// reasonFallback is attached to the metadata of values, that were predicted
+// by the fallback-forecaster.
+const reasonFallback = "fallback: production-model is unavailable"
+
+func init() {
+	config.RootCtx.PersistentFlags().Duration(PathInferenceTimeout, 2*time.Minute, "the maximum duration of a single inference-process")
+	config.Viper.BindPFlag(PathInferenceTimeout, config.RootCtx.PersistentFlags().Lookup(PathInferenceTimeout))
+
+	config.RootCtx.PersistentFlags().Duration(PathTrainingTimeout, 30*time.Minute, "the maximum duration of a single training-process")
+	config.Viper.BindPFlag(PathTrainingTimeout, config.RootCtx.PersistentFlags().Lookup(PathTrainingTimeout))
+
+	config.RootCtx.PersistentFlags().Uint(PathRetries, 2, "the amount of retries of a failed inference- or training-process")
+	config.Viper.BindPFlag(PathRetries, config.RootCtx.PersistentFlags().Lookup(PathRetries))
+
+	config.RootCtx.PersistentFlags().Duration(PathRetryBackoff, time.Second, "the delay before the first retry of a failed process; it is doubled for each further retry")
+	config.Viper.BindPFlag(PathRetryBackoff, config.RootCtx.PersistentFlags().Lookup(PathRetryBackoff))
+
+	config.RootCtx.PersistentFlags().Uint(PathBreakerThreshold, 3, "the amount of consecutive failed inferences after which the fallback-forecaster is used")
+	config.Viper.BindPFlag(PathBreakerThreshold, config.RootCtx.PersistentFlags().Lookup(PathBreakerThreshold))
+
+	config.RootCtx.PersistentFlags().Duration(PathBreakerCooldown, 10*time.Minute, "the duration the fallback-forecaster is used for, before the production-model is tried again")
+	config.Viper.BindPFlag(PathBreakerCooldown, config.RootCtx.PersistentFlags().Lookup(PathBreakerCooldown))
+
+	config.RootCtx.PersistentFlags().String(PathFallback, off, "the forecaster used while the production-model is unavailable (one of: "+off+", "+clearsky+")")
+	config.Viper.BindPFlag(PathFallback, config.RootCtx.PersistentFlags().Lookup(PathFallback))
+
+	config.OnInitialize(func() {
+		inferenceTimeout = config.Viper.GetDuration(PathInferenceTimeout)
+		if inferenceTimeout <= 0 {
+			config.InvalidConfiguration(PathInferenceTimeout, "(0, +inf)")
+		}
+		trainingTimeout = config.Viper.GetDuration(PathTrainingTimeout)
+		if trainingTimeout <= 0 {
+			config.InvalidConfiguration(PathTrainingTimeout, "(0, +inf)")
+		}
+		retries = config.Viper.GetUint(PathRetries)
+		retryBackoff = config.Viper.GetDuration(PathRetryBackoff)
+		if retryBackoff < 0 {
+			config.InvalidConfiguration(PathRetryBackoff, "[0, +inf)")
+		}
+		threshold := config.Viper.GetUint(PathBreakerThreshold)
+		if threshold == 0 {
+			config.InvalidConfiguration(PathBreakerThreshold, "[1, +inf)")
+		}
+		inferenceBreaker = breaker.New(threshold, config.Viper.GetDuration(PathBreakerCooldown))
+		fallbackForecaster = config.Viper.GetString(PathFallback)
+		switch fallbackForecaster {
+		case off:
+		case clearsky:
+			if config.Viper.GetFloat64(PathMaximumProductionPower) <= 0 {
+				config.InvalidConfiguration(PathMaximumProductionPower, "(0, +inf) W")
+			}
+		default:
+			config.InvalidConfiguration(PathFallback, off+", "+clearsky)
+		}
+	})
+}
+
+var (
+	inferenceTimeout   time.Duration
+	trainingTimeout    time.Duration
+	retries            uint
+	retryBackoff       time.Duration
+	fallbackForecaster string
+)
+
+// inferenceBreaker opens after repeated failures of the production-model's
+// inference. The fallback-forecaster is used while it is open.
+var inferenceBreaker *breaker.Breaker
+
+// errUnavailable is the error of an inference-job, that was not run, as the
+// circuit breaker is open.
+var errUnavailable = errors.New("production-model is unavailable")
+
+// errTimeout is returned by execute, if a process exceeded its deadline.
+var errTimeout = errors.New("model-execution exceeded its deadline")
+
+// BreakerStatus returns the status of the circuit breaker guarding the
+// production-model's inference.
+func BreakerStatus() breaker.Status {
+	return inferenceBreaker.Status()
+}
+
+// ResetBreaker closes the circuit breaker guarding the production-model's
+// inference, so that the production-model is used again immediately.
+func ResetBreaker() {
+	inferenceBreaker.Reset()
+	log.Info("circuit breaker was reset")
+}
+
+// Fallback returns the forecaster used while the production-model is
+// unavailable.
+func Fallback() string {
+	return fallbackForecaster
+}
+
+// execute runs the given script with the given input on stdin, killing it
+// after timeout. Failed attempts are retried with exponential backoff, unless
+// answered returns true for their stdout, i.e. the script answered the request
+// and failing again is certain. It returns ctx's error, if ctx is done before
+// the script succeeded.
+func execute(ctx context.Context, timeout time.Duration, script string, input []byte, answered func(stdout []byte) bool) (stdout, stderr []byte, err error) {
+	backoff := retryBackoff
+	for attempt := uint(0); ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		var outb, errb bytes.Buffer
+		cmd := python(attemptCtx, script)
+		cmd.Stdin = bytes.NewReader(input)
+		cmd.Stdout = &outb
+		cmd.Stderr = &errb
+		err = cmd.Run()
+		stdout, stderr = outb.Bytes(), errb.Bytes()
+		if err != nil && attemptCtx.Err() == context.DeadlineExceeded {
+			err = errTimeout
+		}
+		cancel()
+		if ctx.Err() != nil {
+			return stdout, stderr, ctx.Err()
+		}
+		if err == nil || attempt == retries || (err != errTimeout && answered(stdout)) {
+			return stdout, stderr, err
+		}
+
+		log.WithError(err).WithField("script", script).WithField("attempt", attempt+1).WithField("out", string(stderr)).Warn("model-execution failed, retrying...")
+		select {
+		case <-time.After(backoff): // Timeout must not be mocked!
+		case <-ctx.Done():
+			return stdout, stderr, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// reportInference reports the outcome of an inference-job to the circuit
+// breaker. Canceled and rejected jobs are not counted, as they do not tell
+// whether the production-model is available. Jobs, that were not allowed to
+// run, are not reported at all.
+func reportInference(err error) {
+	if err == nil {
+		if inferenceBreaker.Status().State != breaker.Closed {
+			log.Info("production-model recovered, circuit breaker closed")
+		}
+		inferenceBreaker.Success()
+		return
+	}
+	if err == errUnavailable {
+		return
+	}
+	if errors.Is(err, context.Canceled) || isRejection(err) {
+		inferenceBreaker.Release()
+		return
+	}
+	if inferenceBreaker.Failure(timeutils.Now(), err) {
+		log.WithError(err).WithField("cooldown", config.Viper.GetDuration(PathBreakerCooldown)).WithField("fallback", fallbackForecaster).Warn("production-model failed repeatedly, circuit breaker opened")
+	}
+}
+
+// fallback predicts the given amount of steps starting at t using the
+// fallback-forecaster. Steps, that were predicted already, are skipped. The
+// predicted values are marked as being of low confidence.
+func fallback(t time.Time, steps uint) {
+	if fallbackForecaster == off {
+		return
+	}
+	for i := uint(0); i < steps; i++ {
+		t := t.Add(time.Duration(i) * stepsize)
+		if !toBePredicted(cache[t]) {
+			continue
+		}
+		clearSkyInference(t, 1, metadata.Low, reasonFallback)
+	}
+}
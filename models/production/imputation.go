@@ -0,0 +1,236 @@
+package production
+
+import (
+	"sort"
+	"time"
+
+	"github.com/theMomax/openefs/config"
+	"github.com/theMomax/openefs/models/production/weather"
+	"github.com/theMomax/openefs/utils/metadata"
+)
+
+// Config paths
+const (
+	PathImputationProduction = "models.production.imputation.production"
+	PathImputationWeather    = "models.production.imputation.weather"
+	PathImputationMaxGap     = "models.production.imputation.maxgap"
+	PathImputationTrain      = "models.production.imputation.train"
+)
+
+// Imputation strategies
+const (
+	linear = "linear"
+	carry  = "carry"
+)
+
+func init() {
+	config.RootCtx.PersistentFlags().String(PathImputationProduction, off, "the strategy used for filling gaps in the provided production-values (one of: "+off+", "+linear+", "+carry+", "+averageday+")")
+	config.Viper.BindPFlag(PathImputationProduction, config.RootCtx.PersistentFlags().Lookup(PathImputationProduction))
+
+	config.RootCtx.PersistentFlags().String(PathImputationWeather, off, "the strategy used for filling gaps in the provided weather-values (one of: "+off+", "+linear+", "+carry+")")
+	config.Viper.BindPFlag(PathImputationWeather, config.RootCtx.PersistentFlags().Lookup(PathImputationWeather))
+
+	config.RootCtx.PersistentFlags().Uint(PathImputationMaxGap, 3, "the maximum amount of consecutive missing steps, that are imputed")
+	config.Viper.BindPFlag(PathImputationMaxGap, config.RootCtx.PersistentFlags().Lookup(PathImputationMaxGap))
+
+	config.RootCtx.PersistentFlags().Bool(PathImputationTrain, false, "use steps containing imputed values for training the production-model")
+	config.Viper.BindPFlag(PathImputationTrain, config.RootCtx.PersistentFlags().Lookup(PathImputationTrain))
+
+	config.OnInitialize(func() {
+		productionImputation = config.Viper.GetString(PathImputationProduction)
+		switch productionImputation {
+		case off, linear, carry, averageday:
+		default:
+			config.InvalidConfiguration(PathImputationProduction, off+", "+linear+", "+carry+", "+averageday)
+		}
+		weatherImputation = config.Viper.GetString(PathImputationWeather)
+		switch weatherImputation {
+		case off, linear, carry:
+		default:
+			config.InvalidConfiguration(PathImputationWeather, off+", "+linear+", "+carry)
+		}
+		maxGap = config.Viper.GetUint(PathImputationMaxGap)
+		trainOnImputed = config.Viper.GetBool(PathImputationTrain)
+	})
+}
+
+var (
+	productionImputation string
+	weatherImputation    string
+	maxGap               uint
+	trainOnImputed       bool
+)
+
+// weatherUpdate is a weather.Update created by this package.
+type weatherUpdate struct {
+	data    *weather.Data
+	time    time.Time
+	meta    metadata.Metadata
+	imputed bool
+}
+
+func (u *weatherUpdate) Data() *weather.Data {
+	return u.data
+}
+
+func (u *weatherUpdate) Time() time.Time {
+	return u.time
+}
+
+func (u *weatherUpdate) Meta() metadata.Metadata {
+	return u.meta
+}
+
+// isImputed returns true if the given production- or weather-update was
+// imputed by this package.
+func isImputed(u interface{}) bool {
+	switch v := u.(type) {
+	case *update:
+		return v.imputed
+	case *weatherUpdate:
+		return v.imputed
+	default:
+		return false
+	}
+}
+
+// isTrainable returns true if the step's values may be used for training the
+// production-model.
+func isTrainable(c *cupdate) bool {
+	return fullyExists(c) && !c.p.IsDerived() && (trainOnImputed || (!isImputed(c.p) && !isImputed(c.w)))
+}
+
+// impute fills gaps of at most maxGap steps between provided values using the
+// configured strategies. Imputed values are recalculated on each call, so
+// they reflect the latest neighbouring values.
+func impute() {
+	if productionImputation == off && weatherImputation == off {
+		return
+	}
+
+	timestamps := make([]time.Time, 0, len(cache))
+	for t := range cache {
+		timestamps = append(timestamps, t)
+	}
+	if len(timestamps) < 2 {
+		return
+	}
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i].Before(timestamps[j])
+	})
+
+	if productionImputation != off {
+		forEachGap(timestamps[0], timestamps[len(timestamps)-1], func(t time.Time) bool {
+			c := cache[t]
+			return c != nil && c.p != nil && !c.p.IsDerived() && !isImputed(c.p)
+		}, imputeProduction)
+	}
+
+	if weatherImputation != off {
+		forEachGap(timestamps[0], timestamps[len(timestamps)-1], func(t time.Time) bool {
+			c := cache[t]
+			return c != nil && c.w != nil && !isImputed(c.w)
+		}, imputeWeather)
+	}
+}
+
+// forEachGap calls fill for each gap of at most maxGap steps between from and
+// to. A gap is a sequence of steps, for which known returns false, that is
+// preceded and followed by a step, for which known returns true.
+func forEachGap(from, to time.Time, known func(time.Time) bool, fill func(left, right time.Time, steps uint)) {
+	var left *time.Time
+	for t := from; !t.After(to); t = t.Add(stepsize) {
+		if !known(t) {
+			continue
+		}
+		if left != nil {
+			steps := uint(t.Sub(*left)/stepsize) - 1
+			if steps > 0 && steps <= maxGap {
+				fill(*left, t, steps)
+			}
+		}
+		l := t
+		left = &l
+	}
+}
+
+// imputeProduction fills the gap between left and right. Steps holding a
+// forecast are skipped, so that it is neither lost nor mistaken for a
+// measurement.
+func imputeProduction(left, right time.Time, steps uint) {
+	l, r := cache[left].p, cache[right].p
+	for i := uint(1); i <= steps; i++ {
+		t := left.Add(time.Duration(i) * stepsize)
+		if c := cache[t]; c != nil && c.p != nil && c.p.IsDerived() {
+			continue
+		}
+		var power float64
+		switch productionImputation {
+		case linear:
+			power = interpolate(l.Data().Power, r.Data().Power, i, steps)
+		case carry:
+			power = l.Data().Power
+		case averageday:
+			v, ok := averageNonDerived(t)
+			if !ok {
+				continue
+			}
+			power = normalize(v, t)
+		}
+		if cache[t] == nil {
+			cache[t] = &cupdate{}
+		}
+		cache[t].p = &update{
+			data: &Data{
+				Power: power,
+			},
+			time:    t,
+			meta:    latest(l.Meta(), r.Meta()),
+			imputed: true,
+		}
+	}
+	log.WithField("from", left).WithField("to", right).WithField("steps", steps).Trace("imputed production-values")
+}
+
+func imputeWeather(left, right time.Time, steps uint) {
+	l, r := cache[left].w, cache[right].w
+	for i := uint(1); i <= steps; i++ {
+		t := left.Add(time.Duration(i) * stepsize)
+		data := *l.Data()
+		if weatherImputation == linear {
+			data = weather.Data{
+				CloudCover:               interpolate(l.Data().CloudCover, r.Data().CloudCover, i, steps),
+				PrecipitationProbability: interpolate(l.Data().PrecipitationProbability, r.Data().PrecipitationProbability, i, steps),
+				PrecipitationIntensity:   interpolate(l.Data().PrecipitationIntensity, r.Data().PrecipitationIntensity, i, steps),
+				WindSpeed:                interpolate(l.Data().WindSpeed, r.Data().WindSpeed, i, steps),
+				WindGust:                 interpolate(l.Data().WindGust, r.Data().WindGust, i, steps),
+				ApparentTemperature:      interpolate(l.Data().ApparentTemperature, r.Data().ApparentTemperature, i, steps),
+				Temperature:              interpolate(l.Data().Temperature, r.Data().Temperature, i, steps),
+				Humidity:                 interpolate(l.Data().Humidity, r.Data().Humidity, i, steps),
+				DewPoint:                 interpolate(l.Data().DewPoint, r.Data().DewPoint, i, steps),
+				Visibility:               interpolate(l.Data().Visibility, r.Data().Visibility, i, steps),
+				UVIndex:                  interpolate(l.Data().UVIndex, r.Data().UVIndex, i, steps),
+			}
+		}
+		if cache[t] == nil {
+			cache[t] = &cupdate{}
+		}
+		m := latest(l.Meta(), r.Meta())
+		// do not trigger a new prediction, if the imputed value did not change
+		if prev := cache[t].w; prev != nil && weather.Equal(prev.Data(), &data) {
+			m = prev.Meta()
+		}
+		cache[t].w = &weatherUpdate{
+			data:    &data,
+			time:    t,
+			meta:    m,
+			imputed: true,
+		}
+	}
+	log.WithField("from", left).WithField("to", right).WithField("steps", steps).Trace("imputed weather-values")
+}
+
+// interpolate returns the i-th of steps values between l and r.
+func interpolate(l, r float64, i, steps uint) float64 {
+	return l + (r-l)*float64(i)/float64(steps+1)
+}
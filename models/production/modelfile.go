@@ -0,0 +1,171 @@
+package production
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Scripts managing the production-model's file
+const (
+	// validationScript runs a dry inference on a production-model.
+	validationScript = "validate_model_production.py"
+	buildScript      = "build_model_production.py"
+)
+
+// errSchemaMismatch is returned by validateModel, if the model's input or
+// output does not match the Schema.
+var errSchemaMismatch = errors.New("production-model does not match the configured schema")
+
+// prepareModel makes sure, that a valid production-model exists at modelPath.
+// A model, that cannot be loaded or fails a dry inference, is moved aside and
+// replaced by the last good snapshot. The model is rebuilt, if there is no
+// valid snapshot, that matches the configured Schema. The application exits, if the model's Schema does not match
+// the configuration.
+func prepareModel() {
+	if _, err := os.Stat(modelPath); os.IsNotExist(err) {
+		buildModel()
+		return
+	}
+
+	stored, err := readSchema(modelPath)
+	if err != nil && !os.IsNotExist(err) {
+		log.WithError(err).Fatal("could not read schema of production-model")
+	}
+	hasSchema := err == nil
+	if hasSchema {
+		if err := schema.Compare(stored); err != nil {
+			log.WithError(err).WithField("schema", schemaPath(modelPath)).Fatal("configuration does not match the production-model's schema; restore the configuration or remove the model to rebuild it")
+		}
+	}
+
+	err = validateModel(modelPath)
+	if err == errSchemaMismatch {
+		log.WithError(err).Fatal("configuration does not match the production-model; restore the configuration or remove the model to rebuild it")
+	}
+	if err == nil {
+		if !hasSchema {
+			// the model was built before schemas were introduced
+			if err := writeSchema(modelPath, schema); err != nil {
+				log.WithError(err).Fatal("could not write schema of production-model")
+			}
+		}
+		log.Debug("validated production-model")
+		return
+	}
+	log.WithError(err).Warn("production-model is invalid")
+	if err := os.Rename(modelPath, variantPath(modelPath, "corrupt")); err != nil {
+		log.WithError(err).Fatal("could not remove invalid production-model")
+	}
+
+	snapshot := variantPath(modelPath, "last")
+	stored, err = validateSnapshot(snapshot)
+	if err != nil {
+		log.WithError(err).Warn("no valid snapshot of the production-model")
+		buildModel()
+		return
+	}
+	if err := copyFile(snapshot, modelPath); err != nil {
+		log.WithError(err).Fatal("could not restore production-model from snapshot")
+	}
+	if err := writeSchema(modelPath, stored); err != nil {
+		log.WithError(err).Fatal("could not write schema of production-model")
+	}
+	log.Warn("restored production-model from last good snapshot")
+}
+
+// validateSnapshot returns the Schema of the snapshot at path, if it matches
+// the configured one and passes validateModel. The snapshot's Schema is stored
+// alongside it by python/model_files.py.
+func validateSnapshot(path string) (Schema, error) {
+	stored, err := readSchema(path)
+	if err != nil {
+		return stored, err
+	}
+	if err := schema.Compare(stored); err != nil {
+		return stored, errors.New("schema differs: " + err.Error())
+	}
+	return stored, validateModel(path)
+}
+
+// validateModel loads the model at path, checks its input and output against
+// the Schema and runs a dry inference.
+func validateModel(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	out, err := python(context.Background(), validationScript, path, strconv.Itoa(int(schema.Steps())), strconv.Itoa(len(schema.Features)), strconv.Itoa(int(schema.Outputs))).CombinedOutput()
+	if exit, ok := err.(*exec.ExitError); ok && exit.ExitCode() == 2 {
+		log.WithField("out", strings.TrimSpace(string(out))).Debug("schema-mismatch")
+		return errSchemaMismatch
+	}
+	if err != nil {
+		return errors.New(err.Error() + ": " + strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// BuildModel replaces the production-model by a new one, that matches the
+// configured Schema and Spec. The replaced model is kept as snapshot.
+func BuildModel() {
+	buildModel()
+}
+
+// buildModel builds a new model matching the Schema and Spec.
+func buildModel() {
+	log.Info("creating production model...")
+	if err := createModel(modelPath); err != nil {
+		log.WithError(err).Fatal("could not create production-model")
+	}
+	log.Debug("production-model created")
+}
+
+// createModel builds a new, untrained model matching the Schema and Spec at
+// path and stores the Schema alongside it.
+func createModel(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	cmd := python(context.Background(), buildScript, path, strconv.Itoa(int(schema.Steps())), strconv.Itoa(len(schema.Features)), strconv.Itoa(int(schema.Outputs)), spec.encode())
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return errors.New(err.Error() + ": " + strings.TrimSpace(string(out)))
+	}
+	return writeSchema(path, schema)
+}
+
+// variantPath returns the path of the given variant of the model-file at path,
+// e.g. production.last.h5 for production.h5. The naming matches the one of
+// python/model_files.py.
+func variantPath(path, variant string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + variant + ext
+}
+
+// copyFile copies src to dst. dst is replaced atomically.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := variantPath(dst, "tmp")
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
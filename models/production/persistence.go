@@ -0,0 +1,141 @@
+package production
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/theMomax/openefs/models/production/weather"
+	"github.com/theMomax/openefs/persistence"
+	"github.com/theMomax/openefs/utils/metadata"
+	"github.com/theMomax/openefs/utils/numbers"
+	timeutils "github.com/theMomax/openefs/utils/time"
+)
+
+func init() {
+	persistence.Register("models.production.average", saveAverage, loadAverage)
+	persistence.Register("models.production.series", saveSeries, loadSeries)
+}
+
+// averageSnapshot is the persisted form of an element of the average-day
+// recording.
+type averageSnapshot struct {
+	Array      string        `json:"array,omitempty"`
+	DaysAhead  uint          `json:"daysAhead"`
+	StepOfDay  uint          `json:"stepOfDay"`
+	Derived    numbers.State `json:"derived"`
+	NonDerived numbers.State `json:"nonDerived"`
+}
+
+func saveAverage() interface{} {
+	snapshots := make([]averageSnapshot, 0)
+	for _, e := range avgcache.Elements() {
+		v, ok := e.(*element)
+		if !ok {
+			continue
+		}
+		v.m.Lock()
+		snapshots = append(snapshots, averageSnapshot{
+			Array:      v.array,
+			DaysAhead:  v.daysAhead,
+			StepOfDay:  v.stepOfDay,
+			Derived:    v.derived.State(),
+			NonDerived: v.nonderived.State(),
+		})
+		v.m.Unlock()
+	}
+	return snapshots
+}
+
+func loadAverage(b []byte) error {
+	var snapshots []averageSnapshot
+	if err := json.Unmarshal(b, &snapshots); err != nil {
+		return err
+	}
+	for _, s := range snapshots {
+		v := &element{
+			derived:    numbers.NewAverageSum(halfLife),
+			nonderived: numbers.NewAverageSum(halfLife),
+			m:          &sync.Mutex{},
+			array:      s.Array,
+			daysAhead:  s.DaysAhead,
+			stepOfDay:  s.StepOfDay,
+		}
+		v.derived.Restore(s.Derived)
+		v.nonderived.Restore(s.NonDerived)
+		avgcache.Update(v)
+	}
+	return nil
+}
+
+// stepSnapshot is the persisted form of a provided step of the site's or an
+// array's series. Derived and imputed values are not persisted, as they are
+// recalculated. The production is persisted as cached, i.e. normalized.
+type stepSnapshot struct {
+	Array      string        `json:"array,omitempty"`
+	Time       time.Time     `json:"time"`
+	Production *Data         `json:"production,omitempty"`
+	Weather    *weather.Data `json:"weather,omitempty"`
+}
+
+// saveSeries must only be called while the pipeline is not running.
+func saveSeries() interface{} {
+	snapshots := make([]stepSnapshot, 0)
+	eachSeries(func() {
+		for _, steps := range []map[time.Time]*cupdate{history, cache} {
+			for t, c := range steps {
+				s := stepSnapshot{Array: current.id(), Time: t}
+				if c.p != nil && !c.p.IsDerived() && !isImputed(c.p) && !IsIncomplete(c.p) {
+					data := *c.p.Data()
+					s.Production = &data
+				}
+				if c.w != nil && !isImputed(c.w) {
+					data := *c.w.Data()
+					s.Weather = &data
+				}
+				if s.Production != nil || s.Weather != nil {
+					snapshots = append(snapshots, s)
+				}
+			}
+		}
+	})
+	return snapshots
+}
+
+// loadSeries must be called before the pipeline is started. The restored
+// values are not trained on again.
+func loadSeries(b []byte) error {
+	var snapshots []stepSnapshot
+	if err := json.Unmarshal(b, &snapshots); err != nil {
+		return err
+	}
+	for i := range snapshots {
+		s := &snapshots[i]
+		target := siteSeries
+		if s.Array != "" {
+			if target = arraySeries[s.Array]; target == nil {
+				// the array is not configured anymore
+				continue
+			}
+		}
+		// the cache is keyed by the rounded local time
+		t := Round(s.Time)
+		c := target.cache[t]
+		if c == nil {
+			c = &cupdate{}
+			target.cache[t] = c
+		}
+		// the model's id is 0 after startup
+		meta := &metadata.Basic{
+			Timestamp: timeutils.Now(),
+		}
+		if s.Production != nil {
+			c.p = &update{data: s.Production, time: t, meta: meta}
+		}
+		if s.Weather != nil {
+			c.w = &weatherUpdate{data: s.Weather, time: t, meta: meta}
+		}
+	}
+	eachSeries(clearOutdatedCache)
+	return nil
+}
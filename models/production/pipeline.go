@@ -0,0 +1,313 @@
+package production
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/theMomax/openefs/config"
+	"github.com/theMomax/openefs/models/production/weather"
+)
+
+// Config paths
+const (
+	PathWorkers      = "models.production.workers"
+	PathDrainTimeout = "models.production.draintimeout"
+)
+
+func init() {
+	config.RootCtx.PersistentFlags().Uint(PathWorkers, 2, "the amount of inference-processes, that may run concurrently")
+	config.Viper.BindPFlag(PathWorkers, config.RootCtx.PersistentFlags().Lookup(PathWorkers))
+
+	config.RootCtx.PersistentFlags().Duration(PathDrainTimeout, 30*time.Second, "the duration running inference-processes may take to complete on shutdown, before they are canceled")
+	config.Viper.BindPFlag(PathDrainTimeout, config.RootCtx.PersistentFlags().Lookup(PathDrainTimeout))
+
+	config.OnInitialize(func() {
+		workers = config.Viper.GetUint(PathWorkers)
+		if workers == 0 {
+			config.InvalidConfiguration(PathWorkers, "[1, +inf)")
+		}
+		drainTimeout = config.Viper.GetDuration(PathDrainTimeout)
+		if drainTimeout < 0 {
+			config.InvalidConfiguration(PathDrainTimeout, "[0, +inf)")
+		}
+	})
+}
+
+// job is a unit of work, that is executed by a worker, i.e. outside of the
+// goroutine owning the cache. Everything a job requires from the cache is
+// copied, when the job is created.
+type job interface {
+	// run executes the job. It must not access the cache.
+	run(ctx context.Context) result
+}
+
+// result is the outcome of a job.
+type result interface {
+	// apply applies the result to the cache. It is called by the goroutine
+	// owning the cache.
+	apply()
+}
+
+var (
+	workers      uint
+	drainTimeout time.Duration
+)
+
+// The following variables are owned by the goroutine owning the cache.
+var (
+	inferenceQueue []job
+	trainingQueue  []job
+	// running is the amount of jobs passed to a worker, whose result was not
+	// applied yet
+	running int
+	// predicting holds the steps, that are predicted by a queued or running job
+	predicting = make(map[time.Time]bool)
+	// trainingPending is true while a training-job is queued or running
+	trainingPending bool
+	// draining is true once the pipeline shuts down. No new jobs are queued
+	// then.
+	draining bool
+)
+
+var (
+	inferenceJobs chan job
+	trainingJobs  chan job
+	results       chan result
+)
+
+// modelLock prevents the model-file from being read while it is written.
+var modelLock = &sync.RWMutex{}
+
+// Run starts this model's pipeline. The pipeline consists of the following
+// stages:
+//   - ingestion: a single goroutine owns the caches of all series. It receives
+//     weather- and production-updates, decides which steps are to be trained
+//     on or predicted and applies the results of finished jobs.
+//   - execution: a pool of workers runs inference-jobs concurrently, a single
+//     worker runs training-jobs.
+//   - delivery: a goroutine passes all output to the subscribers.
+//
+// Once ctx is done, the pipeline stops accepting updates, processes all
+// buffered updates, waits for running jobs and delivers all pending output.
+// Inference-jobs, that are still running after the drain-timeout, are
+// canceled. The returned channel is closed afterwards.
+//
+// The production-model is validated, restored or built before the pipeline
+// starts.
+func Run(ctx context.Context, bufferSize uint) <-chan struct{} {
+	if config.Viper.GetString(PathForecaster) == network {
+		prepareModel()
+	}
+
+	weatherUpdates = make(chan weather.Update, bufferSize)
+	incomingProductionUpdates = make(chan Update, bufferSize)
+	outgoingProductionUpdates = make(chan Update, bufferSize)
+	inferenceJobs = make(chan job)
+	trainingJobs = make(chan job)
+	results = make(chan result)
+	stopped = make(chan struct{})
+	done := make(chan struct{})
+
+	// running inference-jobs may complete within the drain-timeout
+	inferenceCtx, cancelInference := context.WithCancel(context.Background())
+	go func() {
+		<-ctx.Done()
+		select {
+		case <-time.After(drainTimeout): // Timeout must not be mocked!
+		case <-done:
+		}
+		cancelInference()
+	}()
+
+	wg := &sync.WaitGroup{}
+	for i := uint(0); i < workers; i++ {
+		wg.Add(1)
+		go work(inferenceCtx, inferenceJobs, wg)
+	}
+	// training is never canceled, as this would leave a corrupt model behind
+	wg.Add(1)
+	go work(context.Background(), trainingJobs, wg)
+
+	delivered := make(chan struct{})
+	go func() {
+		for u := range outgoingProductionUpdates {
+			deliver(u)
+		}
+		close(delivered)
+	}()
+
+	go func() {
+		ingest(ctx)
+		drain()
+		close(inferenceJobs)
+		close(trainingJobs)
+		wg.Wait()
+		close(outgoingProductionUpdates)
+		<-delivered
+		log.Info("production-pipeline stopped")
+		close(done)
+	}()
+
+	RunAverage()
+	return done
+}
+
+// ingest runs the ingestion-stage until ctx is done.
+func ingest(ctx context.Context) {
+	// pending arrays time out, even if no further updates are received
+	var timeouts <-chan time.Time
+	if len(arrays) > 0 && arraysTimeout > 0 {
+		ticker := time.NewTicker(arraysTimeout)
+		defer ticker.Stop()
+		timeouts = ticker.C
+	}
+	for {
+		// only offer a job to the workers, if there is one
+		var inference, training chan job
+		var nextInference, nextTraining job
+		if len(inferenceQueue) > 0 {
+			inference = inferenceJobs
+			nextInference = inferenceQueue[0]
+		}
+		if len(trainingQueue) > 0 {
+			training = trainingJobs
+			nextTraining = trainingQueue[0]
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case u := <-incomingProductionUpdates:
+			handleIncomingProduction(u)
+		case wu := <-weatherUpdates:
+			handleIncomingWeather(wu)
+		case r := <-results:
+			running--
+			r.apply()
+		case <-timeouts:
+			sumPendingArrays()
+		case inference <- nextInference:
+			inferenceQueue = inferenceQueue[1:]
+			running++
+		case training <- nextTraining:
+			trainingQueue = trainingQueue[1:]
+			running++
+		}
+	}
+}
+
+// drain stops the intake, processes all buffered updates and waits for all
+// running jobs to finish. Queued jobs are dropped.
+func drain() {
+	log.Info("stopping production-pipeline...")
+	draining = true
+	close(stopped)
+	// wait for updates, that are being passed to the pipeline
+	intake.Lock()
+	defer intake.Unlock()
+
+buffered:
+	for {
+		select {
+		case u := <-incomingProductionUpdates:
+			handleIncomingProduction(u)
+		case wu := <-weatherUpdates:
+			handleIncomingWeather(wu)
+		default:
+			break buffered
+		}
+	}
+
+	for running > 0 {
+		r := <-results
+		running--
+		r.apply()
+	}
+	inferenceQueue, trainingQueue = nil, nil
+}
+
+// work runs the jobs received from jobs until jobs is closed.
+func work(ctx context.Context, jobs <-chan job, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for j := range jobs {
+		results <- j.run(ctx)
+	}
+}
+
+// enqueueInference queues an inference-job. It does nothing, if the pipeline
+// is shutting down.
+func enqueueInference(j *inferenceJob) {
+	if draining {
+		return
+	}
+	for i := uint(0); i < j.steps; i++ {
+		predicting[j.t.Add(time.Duration(i)*stepsize)] = true
+	}
+	inferenceQueue = append(inferenceQueue, j)
+}
+
+// enqueueTraining queues a training-job. It does nothing, if the pipeline is
+// shutting down.
+func enqueueTraining(j *trainingJob) {
+	if draining {
+		return
+	}
+	trainingPending = true
+	trainingQueue = append(trainingQueue, j)
+}
+
+func handleIncomingProduction(u Update) {
+	if isArrayMeasurement(u) {
+		handleArrayUpdate(u)
+		return
+	}
+	u.Data().Power = normalize(u.Data().Power, u.Time())
+	handleProductionUpdate(u)
+}
+
+// handleIncomingWeather passes wu to all series, as the site's arrays share
+// the weather.
+func handleIncomingWeather(wu weather.Update) {
+	eachSeries(func() {
+		handleWeatherUpdate(wu)
+	})
+	sumForecasts()
+	sumPendingArrays()
+}
+
+// emit passes a denormalized copy of the current series' value u to the
+// delivery-stage, so that the cache is not affected.
+func emit(u Update) {
+	c := copyOf(u)
+	c.Data().Power = denormalize(c.Data().Power, c.Time())
+	c.Data().Array = current.id()
+	if current.array != nil && u.IsDerived() {
+		unsummed[Round(u.Time())] = true
+	}
+	outgoingProductionUpdates <- c
+}
+
+// deliver passes u to the subscribers of the site or of its array.
+func deliver(u Update) {
+	if isArrayMeasurement(u) {
+		notifyArrays(u)
+		return
+	}
+	notify(u)
+}
+
+// copyOf returns a copy of u, that does not share its Data with u.
+func copyOf(u Update) Update {
+	data := *u.Data()
+	if v, ok := u.(*update); ok {
+		c := *v
+		c.data = &data
+		return &c
+	}
+	return &update{
+		data: &data,
+		time: u.Time(),
+		meta: u.Meta(),
+	}
+}
@@ -0,0 +1,101 @@
+package production
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// protocolVersion is the version of the protocol used for communicating with
+// the production-model's scripts. It must match the one of python/protocol.py.
+const protocolVersion = 1
+
+// modelRequest is sent to the production-model's scripts via stdin.
+type modelRequest struct {
+	Version int    `json:"version"`
+	Model   string `json:"model"`
+	// Features are the names of each step's features. They must match the
+	// model's Schema.
+	Features []string `json:"features"`
+	// Inputs holds the features of consecutive steps for inference. The
+	// production of steps, that are to be predicted, is zero.
+	Inputs [][]float64 `json:"inputs,omitempty"`
+	// Outputs is the amount of steps to be predicted.
+	Outputs uint `json:"outputs,omitempty"`
+	// Samples holds the samples for training.
+	Samples []sample `json:"samples,omitempty"`
+	// Tests holds the samples predicted after training on Samples in a
+	// backtest.
+	Tests []sample `json:"tests,omitempty"`
+	// Spec describes how the model is trained.
+	Spec *Spec `json:"spec,omitempty"`
+}
+
+// sample is a single training-sample.
+type sample struct {
+	Inputs  [][]float64 `json:"inputs"`
+	Targets []float64   `json:"targets"`
+	// Censored marks each target as a lower bound due to curtailment.
+	Censored []bool `json:"censored"`
+}
+
+// modelResponse is received from the production-model's scripts via stdout.
+type modelResponse struct {
+	Version int       `json:"version"`
+	Output  []float64 `json:"output"`
+	// Error describes why the request was rejected or could not be
+	// processed.
+	Error string `json:"error"`
+}
+
+// rejection is returned by call, if the script answered with an error.
+type rejection struct {
+	message string
+}
+
+func (r *rejection) Error() string {
+	return "model rejected request: " + r.message
+}
+
+// isRejection returns true, if err was returned by call, because the script
+// answered with an error.
+func isRejection(err error) bool {
+	var r *rejection
+	return errors.As(err, &r)
+}
+
+// call sends req to the given script and returns its response. The script's
+// other output is returned in any case.
+func call(ctx context.Context, timeout time.Duration, script string, req modelRequest) (res modelResponse, out []byte, err error) {
+	req.Version = protocolVersion
+	req.Model = modelPath
+	req.Features = featureNames()
+	in, err := json.Marshal(req)
+	if err != nil {
+		return res, nil, err
+	}
+
+	// failures are only retried, if the script crashed without answering
+	stdout, out, err := execute(ctx, timeout, script, in, func(stdout []byte) bool {
+		var res modelResponse
+		return json.Unmarshal(stdout, &res) == nil && res.Error != ""
+	})
+	if uerr := json.Unmarshal(stdout, &res); uerr != nil {
+		if err == nil {
+			err = errors.New("invalid response: " + uerr.Error() + ": " + strings.TrimSpace(string(stdout)))
+		}
+		return res, out, err
+	}
+	if res.Error != "" {
+		return res, out, &rejection{res.Error}
+	}
+	if err != nil {
+		return res, out, err
+	}
+	if res.Version != protocolVersion {
+		return res, out, errors.New("unsupported protocol-version of response")
+	}
+	return res, out, nil
+}
@@ -0,0 +1,49 @@
+package production
+
+import (
+	"time"
+
+	"github.com/theMomax/openefs/utils/metadata"
+)
+
+// Sources of production-values
+const (
+	// SourceMeasurement is the source of values provided by an external
+	// source.
+	SourceMeasurement = "measurement"
+	// SourceNetwork is the source of values predicted by the production-model.
+	SourceNetwork = "network"
+	// SourceClearSky is the source of values predicted by the physical
+	// clear-sky model.
+	SourceClearSky = "clearsky"
+)
+
+// Provenance describes how the value of an Update was obtained.
+type Provenance struct {
+	// Source is one of the Source constants.
+	Source string
+	// Model is the metadata of the production-model, that predicted the
+	// value. It is nil for values, that were not predicted.
+	Model metadata.Metadata
+	// Issued is the time the value was provided or predicted at.
+	Issued time.Time
+	// Confidence is the confidence in the value.
+	Confidence metadata.Confidence
+}
+
+// ProvenanceOf returns the provenance of u.
+func ProvenanceOf(u Update) Provenance {
+	if v, ok := u.(*update); ok && v.derived {
+		return Provenance{
+			Source:     v.source,
+			Model:      v.model,
+			Issued:     v.issued,
+			Confidence: metadata.ConfidenceOf(v.meta),
+		}
+	}
+	return Provenance{
+		Source:     SourceMeasurement,
+		Issued:     u.Meta().Time(),
+		Confidence: metadata.ConfidenceOf(u.Meta()),
+	}
+}
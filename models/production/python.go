@@ -0,0 +1,61 @@
+package production
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/theMomax/openefs/config"
+)
+
+// Config paths
+const (
+	PathModelDirectory  = "models.production.modeldir"
+	PathScriptDirectory = "models.production.scriptdir"
+	PathInterpreter     = "models.production.interpreter"
+	PathEnvironment     = "models.production.env"
+)
+
+func init() {
+	config.RootCtx.PersistentFlags().String(PathModelDirectory, "./python", "the directory the production-model is stored in")
+	config.Viper.BindPFlag(PathModelDirectory, config.RootCtx.PersistentFlags().Lookup(PathModelDirectory))
+
+	config.RootCtx.PersistentFlags().String(PathScriptDirectory, "./python", "the directory containing the production-model's python-scripts")
+	config.Viper.BindPFlag(PathScriptDirectory, config.RootCtx.PersistentFlags().Lookup(PathScriptDirectory))
+
+	config.RootCtx.PersistentFlags().String(PathInterpreter, "python3", "the python-interpreter running the production-model's scripts (e.g. the one of a virtual environment)")
+	config.Viper.BindPFlag(PathInterpreter, config.RootCtx.PersistentFlags().Lookup(PathInterpreter))
+
+	config.RootCtx.PersistentFlags().StringSlice(PathEnvironment, []string{}, "additional environment-variables for the python-interpreter, each given as <key>=<value>")
+	config.Viper.BindPFlag(PathEnvironment, config.RootCtx.PersistentFlags().Lookup(PathEnvironment))
+
+	config.OnInitialize(func() {
+		interpreter = config.Viper.GetString(PathInterpreter)
+		if interpreter == "" {
+			config.InvalidConfiguration(PathInterpreter, "the name or path of a python-interpreter")
+		}
+		scriptDirectory = config.Viper.GetString(PathScriptDirectory)
+		environment = append(os.Environ(), config.Viper.GetStringSlice(PathEnvironment)...)
+		for _, e := range config.Viper.GetStringSlice(PathEnvironment) {
+			if strings.Index(e, "=") <= 0 {
+				config.InvalidConfiguration(PathEnvironment, "a list of <key>=<value>")
+			}
+		}
+	})
+}
+
+var (
+	interpreter     string
+	scriptDirectory string
+	environment     []string
+)
+
+// python returns the command running the given script from the configured
+// script-directory. The command is killed, once ctx is done.
+func python(ctx context.Context, script string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, interpreter, append([]string{filepath.Join(scriptDirectory, script)}, args...)...)
+	cmd.Env = environment
+	return cmd
+}
@@ -0,0 +1,388 @@
+package production
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/theMomax/openefs/config"
+	"github.com/theMomax/openefs/models/production/weather"
+	timeutils "github.com/theMomax/openefs/utils/time"
+)
+
+// schemaVersion is the version of the Schema's format.
+const schemaVersion = 1
+
+// Schema describes the input and output of a production-model. It is stored
+// alongside each model, so that a model is never used with a configuration it
+// was not built for.
+type Schema struct {
+	Version int `json:"version"`
+	// Features are the features of each step in the order they are passed to
+	// the model.
+	Features []FeatureSchema `json:"features"`
+	// Window is the amount of steps preceding each prediction, that are passed
+	// to the model.
+	Window uint `json:"window"`
+	// Outputs is the amount of steps predicted at once.
+	Outputs uint `json:"outputs"`
+	// Strategy is the strategy used for multi-step forecasts.
+	Strategy string `json:"strategy"`
+}
+
+// FeatureSchema describes a single feature of a step.
+type FeatureSchema struct {
+	Name string `json:"name"`
+	// Normalization is the method used for normalizing the feature. It is
+	// empty, if the feature is passed as is.
+	Normalization string `json:"normalization,omitempty"`
+}
+
+// Config paths
+const (
+	PathFeatures = "models.production.features"
+)
+
+func init() {
+	config.RootCtx.PersistentFlags().StringSlice(PathFeatures, defaultFeatures, "the features passed to the production-model for each step (any of: "+strings.Join(featureCatalog(), ", ")+")")
+	config.Viper.BindPFlag(PathFeatures, config.RootCtx.PersistentFlags().Lookup(PathFeatures))
+}
+
+// defaultFeatures are the features passed to the production-model, if no
+// other features are configured.
+var defaultFeatures = []string{
+	"yearProcess",
+	"dayProcess",
+	"production",
+	"cloudCover",
+	"precipitationProbability",
+	"windSpeed",
+	"windGust",
+	"precipitationIntensity",
+	"apparentTemperature",
+	"humidity",
+	"dewPoint",
+	"visibility",
+	"uvIndex",
+	"temperature",
+}
+
+// feature is a feature, that can be passed to the production-model.
+type feature struct {
+	name string
+	// normalized is true, if the feature is normalized using the configured
+	// normalization-method
+	normalized bool
+	// lookback is the amount of steps preceding t, that value reads from the
+	// cache
+	lookback uint
+	// value returns the feature's value for the step at t. known is false, if
+	// the step's production-value is to be predicted.
+	value func(t time.Time, c *cupdate, known bool) float64
+}
+
+// weatherFields holds the fields of weather.Data, that can be passed to the
+// production-model.
+var weatherFields = map[string]func(*weather.Data) float64{
+	"cloudCover":               func(w *weather.Data) float64 { return w.CloudCover },
+	"precipitationProbability": func(w *weather.Data) float64 { return w.PrecipitationProbability },
+	"windSpeed":                func(w *weather.Data) float64 { return w.WindSpeed },
+	"windGust":                 func(w *weather.Data) float64 { return w.WindGust },
+	"precipitationIntensity":   func(w *weather.Data) float64 { return w.PrecipitationIntensity },
+	"apparentTemperature":      func(w *weather.Data) float64 { return w.ApparentTemperature },
+	"humidity":                 func(w *weather.Data) float64 { return w.Humidity },
+	"dewPoint":                 func(w *weather.Data) float64 { return w.DewPoint },
+	"visibility":               func(w *weather.Data) float64 { return w.Visibility },
+	"uvIndex":                  func(w *weather.Data) float64 { return w.UVIndex },
+	"temperature":              func(w *weather.Data) float64 { return w.Temperature },
+}
+
+// lags holds the lagged production-features and how far they look back.
+var lags = map[string]time.Duration{
+	"productionLag24h": 24 * time.Hour,
+}
+
+// registry holds all features, that can be passed to the production-model,
+// except for the lagged production, the weather-fields and their rolling
+// averages.
+var registry = []feature{
+	{name: "yearProcess", value: func(t time.Time, c *cupdate, known bool) float64 {
+		return timeutils.YearProcess(t.In(location))
+	}},
+	{name: "dayProcess", value: func(t time.Time, c *cupdate, known bool) float64 {
+		return timeutils.DayProcess(t.In(location))
+	}},
+	{name: "hourOfDaySin", value: func(t time.Time, c *cupdate, known bool) float64 {
+		return math.Sin(2 * math.Pi * timeutils.DayProcess(t.In(location)))
+	}},
+	{name: "hourOfDayCos", value: func(t time.Time, c *cupdate, known bool) float64 {
+		return math.Cos(2 * math.Pi * timeutils.DayProcess(t.In(location)))
+	}},
+	{name: "dayOfYearSin", value: func(t time.Time, c *cupdate, known bool) float64 {
+		return math.Sin(2 * math.Pi * timeutils.YearProcess(t.In(location)))
+	}},
+	{name: "dayOfYearCos", value: func(t time.Time, c *cupdate, known bool) float64 {
+		return math.Cos(2 * math.Pi * timeutils.YearProcess(t.In(location)))
+	}},
+	{name: "production", normalized: true, value: func(t time.Time, c *cupdate, known bool) float64 {
+		if !known {
+			return 0
+		}
+		return c.p.Data().Power
+	}},
+}
+
+// rollingSuffix separates a weather-field from the amount of steps its rolling
+// average is computed over, e.g. cloudCoverMean3.
+const rollingSuffix = "Mean"
+
+func weatherFeature(name string, value func(*weather.Data) float64) feature {
+	return feature{
+		name: name,
+		value: func(t time.Time, c *cupdate, known bool) float64 {
+			return value(c.w.Data())
+		},
+	}
+}
+
+// lagFeature returns a feature, that is the production lag before the step. If
+// it is not cached, the average day is used instead.
+func lagFeature(name string, lag time.Duration) feature {
+	steps := uint(lag / stepsize)
+	return feature{
+		name:       name,
+		normalized: true,
+		lookback:   steps,
+		value: func(t time.Time, c *cupdate, known bool) float64 {
+			l := t.Add(-time.Duration(steps) * stepsize)
+			if p := lookup(l); p != nil && p.p != nil {
+				return p.p.Data().Power
+			}
+			if v, ok := averageNonDerived(l); ok {
+				return normalize(v, l)
+			}
+			return 0
+		},
+	}
+}
+
+// rollingFeature returns a feature, that is the average of value over the
+// step and the steps-1 preceding ones. Steps without weather are skipped.
+func rollingFeature(name string, steps uint, value func(*weather.Data) float64) feature {
+	return feature{
+		name:     name,
+		lookback: steps - 1,
+		value: func(t time.Time, c *cupdate, known bool) float64 {
+			sum, n := value(c.w.Data()), 1.0
+			for i := uint(1); i < steps; i++ {
+				if p := lookup(t.Add(-time.Duration(i) * stepsize)); weatherExists(p) {
+					sum += value(p.w.Data())
+					n++
+				}
+			}
+			return sum / n
+		},
+	}
+}
+
+// lookupFeature returns the feature with the given name.
+func lookupFeature(name string) (feature, bool) {
+	for _, f := range registry {
+		if f.name == name {
+			return f, true
+		}
+	}
+	if lag, ok := lags[name]; ok {
+		return lagFeature(name, lag), true
+	}
+	if value, ok := weatherFields[name]; ok {
+		return weatherFeature(name, value), true
+	}
+	if i := strings.LastIndex(name, rollingSuffix); i > 0 {
+		value, ok := weatherFields[name[:i]]
+		steps, err := strconv.ParseUint(name[i+len(rollingSuffix):], 10, 32)
+		if ok && err == nil && steps >= 2 {
+			return rollingFeature(name, uint(steps), value), true
+		}
+	}
+	return feature{}, false
+}
+
+// featureCatalog returns the names of all features, that can be configured.
+func featureCatalog() []string {
+	names := make([]string, 0, len(registry)+len(lags)+len(weatherFields)+1)
+	for _, f := range registry {
+		names = append(names, f.name)
+	}
+	lagged := make([]string, 0, len(lags))
+	for name := range lags {
+		lagged = append(lagged, name)
+	}
+	sort.Strings(lagged)
+	names = append(names, lagged...)
+	fields := make([]string, 0, len(weatherFields))
+	for name := range weatherFields {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	names = append(names, fields...)
+	return append(names, "<weather-field>"+rollingSuffix+"<steps>")
+}
+
+// features are the features passed to the production-model.
+var features []feature
+
+// lookback is the amount of steps preceding a step, that are read by any of the
+// features.
+var lookback uint
+
+// schema is the Schema required by the configuration.
+var schema Schema
+
+// configureSchema derives the Schema required by the configuration.
+func configureSchema() {
+	// the recursive strategy feeds each prediction back as production
+	requireProduction := strategy == recursive && config.Viper.GetString(PathForecaster) != clearsky
+	var err error
+	if features, lookback, err = selectFeatures(config.Viper.GetStringSlice(PathFeatures), requireProduction); err != nil {
+		config.InvalidConfiguration(PathFeatures, err.Error())
+	}
+
+	schema = Schema{
+		Version:  schemaVersion,
+		Features: make([]FeatureSchema, 0, len(features)),
+		Window:   requiredPreceding,
+		Outputs:  outputSteps,
+		Strategy: strategy,
+	}
+	for _, f := range features {
+		fs := FeatureSchema{Name: f.name}
+		if f.normalized {
+			fs.Normalization = config.Viper.GetString(PathNormalizationMethod)
+		}
+		schema.Features = append(schema.Features, fs)
+	}
+}
+
+// selectFeatures returns the features with the given names and the amount of
+// steps they look back. The returned error describes the expected names.
+func selectFeatures(names []string, requireProduction bool) ([]feature, uint, error) {
+	if len(names) == 0 {
+		return nil, 0, errors.New("a non-empty list of features")
+	}
+	selected := make([]feature, 0, len(names))
+	var lookback uint
+	seen := make(map[string]bool)
+	for _, name := range names {
+		f, ok := lookupFeature(name)
+		if !ok || seen[name] {
+			return nil, 0, errors.New("a list of distinct features (any of: " + strings.Join(featureCatalog(), ", ") + ")")
+		}
+		seen[name] = true
+		selected = append(selected, f)
+		if f.lookback > lookback {
+			lookback = f.lookback
+		}
+	}
+	if requireProduction && !seen["production"] {
+		return nil, 0, errors.New("a list including production if " + PathStrategy + " is " + recursive)
+	}
+	return selected, lookback, nil
+}
+
+// featureNames returns the names of the features passed to the
+// production-model.
+func featureNames() []string {
+	names := make([]string, 0, len(features))
+	for _, f := range features {
+		names = append(names, f.name)
+	}
+	return names
+}
+
+// row returns the features of the step at t. The production-value is replaced
+// by zero, if it is not known.
+func row(t time.Time, known bool) []float64 {
+	r := make([]float64, 0, len(features))
+	for _, f := range features {
+		r = append(r, f.value(t, cache[t], known))
+	}
+	return r
+}
+
+// Steps returns the amount of steps passed to the model for a single
+// prediction.
+func (s Schema) Steps() uint {
+	if s.Strategy == direct {
+		return s.Window + s.Outputs
+	}
+	return s.Window
+}
+
+// Compare returns an error describing the first difference between s and
+// other.
+func (s Schema) Compare(other Schema) error {
+	if s.Version != other.Version {
+		return fmt.Errorf("version %d differs from %d", s.Version, other.Version)
+	}
+	if s.Strategy != other.Strategy {
+		return fmt.Errorf("strategy %s differs from %s", s.Strategy, other.Strategy)
+	}
+	if s.Window != other.Window {
+		return fmt.Errorf("window of %d steps differs from %d", s.Window, other.Window)
+	}
+	if s.Outputs != other.Outputs {
+		return fmt.Errorf("%d outputs differ from %d", s.Outputs, other.Outputs)
+	}
+	if len(s.Features) != len(other.Features) {
+		return fmt.Errorf("%d features differ from %d", len(s.Features), len(other.Features))
+	}
+	for i := range s.Features {
+		if s.Features[i] != other.Features[i] {
+			return fmt.Errorf("feature %d (%+v) differs from %+v", i, s.Features[i], other.Features[i])
+		}
+	}
+	return nil
+}
+
+// schemaPath returns the path of the Schema stored alongside the model at
+// path, e.g. production.schema.json for production.h5. The naming matches the
+// one of python/protocol.py.
+func schemaPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".schema.json"
+}
+
+// readSchema reads the Schema stored alongside the model at path.
+func readSchema(path string) (Schema, error) {
+	var s Schema
+	b, err := ioutil.ReadFile(schemaPath(path))
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(b, &s); err != nil {
+		return s, errors.New("invalid schema " + schemaPath(path) + ": " + err.Error())
+	}
+	return s, nil
+}
+
+// writeSchema stores s alongside the model at path. The file is replaced
+// atomically.
+func writeSchema(path string, s Schema) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := schemaPath(path) + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, schemaPath(path))
+}
@@ -0,0 +1,176 @@
+package production
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/theMomax/openefs/config"
+	"github.com/theMomax/openefs/models/production/weather"
+)
+
+func init() {
+	stepsize = time.Hour
+}
+
+func TestLookupFeature(t *testing.T) {
+	cases := []struct {
+		name       string
+		ok         bool
+		normalized bool
+		lookback   uint
+	}{
+		{name: "production", ok: true, normalized: true},
+		{name: "yearProcess", ok: true},
+		{name: "cloudCover", ok: true},
+		{name: "cloudCoverMean3", ok: true, lookback: 2},
+		{name: "temperatureMean24", ok: true, lookback: 23},
+		{name: "productionLag24h", ok: true, normalized: true, lookback: 24},
+		// a rolling average requires at least two steps
+		{name: "cloudCoverMean1"},
+		{name: "cloudCoverMean"},
+		{name: "cloudCoverMeanX"},
+		// only weather-fields can be averaged
+		{name: "productionMean3"},
+		{name: "Mean3"},
+		{name: "productionLag12h"},
+		{name: "unknown"},
+		{name: ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			f, ok := lookupFeature(c.name)
+			assert.Equal(t, c.ok, ok)
+			if !c.ok {
+				return
+			}
+			assert.Equal(t, c.name, f.name)
+			assert.Equal(t, c.normalized, f.normalized)
+			assert.Equal(t, c.lookback, f.lookback)
+		})
+	}
+}
+
+func TestRollingFeatureSkipsMissingWeather(t *testing.T) {
+	defer func(c, h map[time.Time]*cupdate) { cache, history = c, h }(cache, history)
+	start := time.Unix(0, 0)
+	cache = map[time.Time]*cupdate{
+		start:                    {w: &weatherUpdate{data: &weather.Data{CloudCover: 0.2}}},
+		start.Add(2 * time.Hour): {w: &weatherUpdate{data: &weather.Data{CloudCover: 0.6}}},
+		start.Add(3 * time.Hour): {w: &weatherUpdate{data: &weather.Data{CloudCover: 0.7}}},
+	}
+	history = make(map[time.Time]*cupdate)
+
+	f, ok := lookupFeature("cloudCoverMean3")
+	assert.True(t, ok)
+	at := start.Add(2 * time.Hour)
+	assert.InDelta(t, 0.4, f.value(at, cache[at], true), 1e-9)
+	at = start.Add(3 * time.Hour)
+	assert.InDelta(t, 0.65, f.value(at, cache[at], true), 1e-9)
+}
+
+func TestSelectFeatures(t *testing.T) {
+	cases := []struct {
+		name              string
+		names             []string
+		requireProduction bool
+		lookback          uint
+		err               string
+	}{
+		{name: "defaults", names: defaultFeatures, requireProduction: true},
+		{name: "lagged and rolling", names: []string{"production", "productionLag24h", "cloudCoverMean3"}, requireProduction: true, lookback: 24},
+		{name: "rolling", names: []string{"cloudCover", "cloudCoverMean6"}, lookback: 5},
+		{name: "without production", names: []string{"cloudCover"}},
+		{name: "recursive without production", names: []string{"cloudCover"}, requireProduction: true, err: "including production"},
+		{name: "duplicate", names: []string{"production", "cloudCover", "production"}, err: "distinct features"},
+		{name: "duplicate rolling", names: []string{"cloudCoverMean3", "cloudCoverMean3"}, err: "distinct features"},
+		{name: "unknown", names: []string{"production", "sunshine"}, err: "distinct features"},
+		{name: "empty", names: []string{}, err: "non-empty"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			selected, lookback, err := selectFeatures(c.names, c.requireProduction)
+			if c.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), c.err)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			names := make([]string, 0, len(selected))
+			for _, f := range selected {
+				names = append(names, f.name)
+			}
+			assert.Equal(t, c.names, names)
+			assert.Equal(t, c.lookback, lookback)
+		})
+	}
+}
+
+func TestConfigureSchema(t *testing.T) {
+	defer func(s string, w, o uint) { strategy, requiredPreceding, outputSteps = s, w, o }(strategy, requiredPreceding, outputSteps)
+	defer config.Viper.Set(PathFeatures, nil)
+	defer config.Viper.Set(PathNormalizationMethod, nil)
+	strategy, requiredPreceding, outputSteps = direct, 3, 2
+	config.Viper.Set(PathFeatures, []string{"cloudCover", "productionLag24h", "cloudCoverMean3"})
+	config.Viper.Set(PathNormalizationMethod, clearsky)
+
+	configureSchema()
+
+	assert.Equal(t, Schema{
+		Version: schemaVersion,
+		Features: []FeatureSchema{
+			{Name: "cloudCover"},
+			{Name: "productionLag24h", Normalization: clearsky},
+			{Name: "cloudCoverMean3"},
+		},
+		Window:   3,
+		Outputs:  2,
+		Strategy: direct,
+	}, schema)
+	assert.Equal(t, uint(24), lookback)
+	assert.Equal(t, []string{"cloudCover", "productionLag24h", "cloudCoverMean3"}, featureNames())
+	assert.Equal(t, uint(5), schema.Steps())
+}
+
+func TestSchemaCompare(t *testing.T) {
+	base := func() Schema {
+		return Schema{
+			Version:  schemaVersion,
+			Features: []FeatureSchema{{Name: "production", Normalization: maxpower}, {Name: "cloudCover"}},
+			Window:   24,
+			Outputs:  1,
+			Strategy: recursive,
+		}
+	}
+	cases := []struct {
+		name   string
+		modify func(*Schema)
+		err    string
+	}{
+		{name: "equal", modify: func(s *Schema) {}},
+		{name: "version", modify: func(s *Schema) { s.Version++ }, err: "version"},
+		{name: "strategy", modify: func(s *Schema) { s.Strategy = direct }, err: "strategy"},
+		{name: "window", modify: func(s *Schema) { s.Window = 12 }, err: "window"},
+		{name: "outputs", modify: func(s *Schema) { s.Outputs = 24 }, err: "outputs"},
+		{name: "additional feature", modify: func(s *Schema) { s.Features = append(s.Features, FeatureSchema{Name: "temperature"}) }, err: "features"},
+		{name: "feature order", modify: func(s *Schema) { s.Features[0], s.Features[1] = s.Features[1], s.Features[0] }, err: "feature 0"},
+		{name: "normalization", modify: func(s *Schema) { s.Features[0].Normalization = averageday }, err: "feature 0"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			other := base()
+			c.modify(&other)
+			err := base().Compare(other)
+			if c.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), c.err)
+			}
+			// the difference is detected in both directions
+			assert.Error(t, other.Compare(base()))
+		})
+	}
+}
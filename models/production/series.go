@@ -0,0 +1,209 @@
+package production
+
+import (
+	"sort"
+	"time"
+
+	"github.com/theMomax/openefs/models/production/weather"
+	"github.com/theMomax/openefs/utils/metadata"
+	"github.com/theMomax/openefs/utils/solar"
+	timeutils "github.com/theMomax/openefs/utils/time"
+)
+
+// series is the production of the whole site or of a single array together
+// with the state required for forecasting it. Each array is forecasted
+// separately using the shared production-model. The goroutine owning the cache
+// works on a single series at a time, which is loaded into this package's
+// state (see within).
+type series struct {
+	// array is nil for the whole site
+	array      *Array
+	cache      map[time.Time]*cupdate
+	history    map[time.Time]*cupdate
+	predicting map[time.Time]bool
+}
+
+var (
+	// siteSeries holds the production of the whole site. If arrays are
+	// configured, it holds their sum and is not forecasted itself.
+	siteSeries = &series{
+		cache:      cache,
+		history:    history,
+		predicting: predicting,
+	}
+	// arraySeries holds each array's series by id.
+	arraySeries = make(map[string]*series)
+	// current is the series loaded into this package's state.
+	current = siteSeries
+	// unsummed holds the steps, for which an array's forecast changed, but
+	// the site's forecast was not updated yet.
+	unsummed = make(map[time.Time]bool)
+)
+
+func newSeries(a *Array) *series {
+	return &series{
+		array:      a,
+		cache:      make(map[time.Time]*cupdate),
+		history:    make(map[time.Time]*cupdate),
+		predicting: make(map[time.Time]bool),
+	}
+}
+
+// id returns the id of the series' array. It is empty for the whole site.
+func (s *series) id() string {
+	if s.array == nil {
+		return ""
+	}
+	return s.array.ID
+}
+
+// peakPower returns the peak-power of the series' array or of the site.
+func (s *series) peakPower() float64 {
+	if s.array == nil {
+		return maximumProductionPower
+	}
+	return s.array.PeakPower
+}
+
+// clearSkyPower returns the average power (in Watts) the series' array or the
+// site would produce under a cloudless sky during the time-step at t.
+func (s *series) clearSkyPower(t time.Time) float64 {
+	if s.array == nil {
+		return ClearSkyPower(t)
+	}
+	return clearSkyPower(s.array.Plane(), s.array.PeakPower, t)
+}
+
+// clearSkyForecast returns the power (in Watts) the series' array or the site
+// is expected to produce during the time-step at t, if the sky is covered as
+// described by w.
+func (s *series) clearSkyForecast(t time.Time, w *weather.Data) float64 {
+	if w == nil {
+		return s.clearSkyPower(t)
+	}
+	return s.clearSkyPower(t) * solar.CloudAttenuation(w.CloudCover)
+}
+
+// isAggregate returns true, if s is the sum of the arrays' series.
+func (s *series) isAggregate() bool {
+	return s.array == nil && len(arrays) > 0
+}
+
+// within loads s into this package's state, calls f and stores the state of s
+// afterwards. It must only be called by the goroutine owning the cache.
+func within(s *series, f func()) {
+	prev := current
+	load(s)
+	defer load(prev)
+	f()
+}
+
+// eachSeries calls f within the site's and each array's series.
+func eachSeries(f func()) {
+	within(siteSeries, f)
+	for _, a := range arrays {
+		within(arraySeries[a.ID], f)
+	}
+}
+
+func load(s *series) {
+	current.cache, current.history, current.predicting = cache, history, predicting
+	current = s
+	cache, history, predicting = s.cache, s.history, s.predicting
+}
+
+// sumForecasts updates the site's forecast for each step, for which an
+// array's forecast changed. The site's forecast is the sum of the arrays'
+// values. It is only updated once no array is being predicted at that step.
+// Measured values of the site are never replaced.
+func sumForecasts() {
+	if len(unsummed) == 0 {
+		return
+	}
+	timestamps := make([]time.Time, 0, len(unsummed))
+	for t := range unsummed {
+		timestamps = append(timestamps, t)
+	}
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i].Before(timestamps[j])
+	})
+
+	now := timeutils.Now()
+	for _, t := range timestamps {
+		if now.Sub(t) >= outdated {
+			delete(unsummed, t)
+			continue
+		}
+		values := make([]Update, 0, len(arrays))
+		for _, a := range arrays {
+			s := arraySeries[a.ID]
+			if c := s.cache[t]; c != nil && c.p != nil && !s.predicting[t] {
+				values = append(values, c.p)
+			}
+		}
+		if len(values) < len(arrays) {
+			continue
+		}
+		delete(unsummed, t)
+		sumForecast(t, values)
+	}
+}
+
+// sumForecast caches the sum of the arrays' normalized values at t as the
+// site's derived value.
+func sumForecast(t time.Time, values []Update) {
+	sum := 0.0
+	var m metadata.Metadata
+	source := SourceNetwork
+	for i, a := range arrays {
+		u := values[i]
+		within(arraySeries[a.ID], func() {
+			sum += denormalize(u.Data().Power, t)
+		})
+		if i == 0 || u.Meta().ID() > m.ID() {
+			m = u.Meta()
+		}
+		if ProvenanceOf(u).Source == SourceClearSky {
+			source = SourceClearSky
+		}
+	}
+	// the site's value is as reliable as the least reliable array's one
+	for _, u := range values {
+		if c := metadata.ConfidenceOf(u.Meta()); c > metadata.ConfidenceOf(m) {
+			m = &metadata.Annotated{
+				Metadata: m,
+				Quality:  c,
+				Reason:   reasonOf(u.Meta()),
+			}
+		}
+	}
+
+	within(siteSeries, func() {
+		if cache[t] == nil {
+			cache[t] = &cupdate{}
+		}
+		if c := cache[t]; c.p != nil && !c.p.IsDerived() {
+			return
+		}
+		cache[t].p = &update{
+			data: &Data{
+				Power: normalize(sum, t),
+			},
+			time:    t,
+			meta:    m,
+			derived: true,
+			source:  source,
+			model:   model,
+			issued:  timeutils.Now(),
+		}
+		emit(cache[t].p)
+	})
+}
+
+// reasonOf returns the reason attached to m, if any.
+func reasonOf(m metadata.Metadata) string {
+	if a, ok := m.(*metadata.Annotated); ok {
+		return a.Reason
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package production
+
+import (
+	"time"
+
+	"github.com/theMomax/openefs/config"
+	"github.com/theMomax/openefs/utils/solar"
+	timeutils "github.com/theMomax/openefs/utils/time"
+)
+
+// Config paths
+const (
+	PathSiteLatitude  = "models.production.site.latitude"
+	PathSiteLongitude = "models.production.site.longitude"
+	PathSiteTilt      = "models.production.site.tilt"
+	PathSiteAzimuth   = "models.production.site.azimuth"
+	PathSiteAlbedo    = "models.production.site.albedo"
+	PathSiteTimeZone  = "models.production.site.timezone"
+)
+
+func init() {
+	config.RootCtx.PersistentFlags().Float64(PathSiteLatitude, 0, "the site's latitude (in degrees, north is positive)")
+	config.Viper.BindPFlag(PathSiteLatitude, config.RootCtx.PersistentFlags().Lookup(PathSiteLatitude))
+
+	config.RootCtx.PersistentFlags().Float64(PathSiteLongitude, 0, "the site's longitude (in degrees, east is positive)")
+	config.Viper.BindPFlag(PathSiteLongitude, config.RootCtx.PersistentFlags().Lookup(PathSiteLongitude))
+
+	config.RootCtx.PersistentFlags().Float64(PathSiteTilt, 0, "the PV-array's tilt (in degrees, 0 is horizontal)")
+	config.Viper.BindPFlag(PathSiteTilt, config.RootCtx.PersistentFlags().Lookup(PathSiteTilt))
+
+	config.RootCtx.PersistentFlags().Float64(PathSiteAzimuth, 180, "the direction the PV-array faces (in degrees clockwise from north, i.e. 180 is south)")
+	config.Viper.BindPFlag(PathSiteAzimuth, config.RootCtx.PersistentFlags().Lookup(PathSiteAzimuth))
+
+	config.RootCtx.PersistentFlags().Float64(PathSiteAlbedo, solar.DefaultAlbedo, "the reflectance of the ground surrounding the PV-array")
+	config.Viper.BindPFlag(PathSiteAlbedo, config.RootCtx.PersistentFlags().Lookup(PathSiteAlbedo))
+
+	config.RootCtx.PersistentFlags().String(PathSiteTimeZone, "Local", "the site's IANA time zone (e.g. Europe/Berlin), which defines the boundaries of days")
+	config.Viper.BindPFlag(PathSiteTimeZone, config.RootCtx.PersistentFlags().Lookup(PathSiteTimeZone))
+
+	config.OnInitialize(func() {
+		loc, err := time.LoadLocation(config.Viper.GetString(PathSiteTimeZone))
+		if err != nil {
+			config.InvalidConfiguration(PathSiteTimeZone, "an IANA time zone name")
+		}
+		location = loc
+	})
+
+	config.OnInitialize(func() {
+		site = solar.Plane{
+			Latitude:  config.Viper.GetFloat64(PathSiteLatitude),
+			Longitude: config.Viper.GetFloat64(PathSiteLongitude),
+			Tilt:      config.Viper.GetFloat64(PathSiteTilt),
+			Azimuth:   config.Viper.GetFloat64(PathSiteAzimuth),
+			Albedo:    config.Viper.GetFloat64(PathSiteAlbedo),
+		}
+		if site.Latitude < -90 || site.Latitude > 90 {
+			config.InvalidConfiguration(PathSiteLatitude, "[-90, 90] degrees")
+		}
+		if site.Longitude < -180 || site.Longitude > 180 {
+			config.InvalidConfiguration(PathSiteLongitude, "[-180, 180] degrees")
+		}
+		if site.Tilt < 0 || site.Tilt > 90 {
+			config.InvalidConfiguration(PathSiteTilt, "[0, 90] degrees")
+		}
+		if site.Azimuth < 0 || site.Azimuth >= 360 {
+			config.InvalidConfiguration(PathSiteAzimuth, "[0, 360) degrees")
+		}
+	})
+}
+
+var site solar.Plane
+
+var location = time.Local
+
+// Site returns the plane describing the location and orientation of the
+// PV-array.
+func Site() solar.Plane {
+	return site
+}
+
+// Location returns the site's time zone.
+func Location() *time.Location {
+	return location
+}
+
+// Midnight returns the start of the day t belongs to in the site's time zone.
+func Midnight(t time.Time) time.Time {
+	t = t.In(location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, location)
+}
+
+// DaysAhead returns the amount of days (in the site's time zone) between today
+// and the day t belongs to. It returns 0 for days in the past.
+func DaysAhead(t time.Time) uint {
+	today := Midnight(Round(timeutils.Now()))
+	day := Midnight(Round(t))
+	if !day.After(today) {
+		return 0
+	}
+	// days are counted on the calendar, as they may not be 24h long
+	days := uint(0)
+	for d := today; d.Before(day); d = d.AddDate(0, 0, 1) {
+		days++
+	}
+	return days
+}
@@ -0,0 +1,123 @@
+package production
+
+import (
+	"encoding/json"
+
+	"github.com/theMomax/openefs/config"
+)
+
+// Config paths
+const (
+	PathSpecLayers       = "models.production.spec.layers"
+	PathSpecUnits        = "models.production.spec.units"
+	PathSpecOptimizer    = "models.production.spec.optimizer"
+	PathSpecLearningRate = "models.production.spec.learningrate"
+	PathSpecLoss         = "models.production.spec.loss"
+	PathSpecEpochs       = "models.production.spec.epochs"
+)
+
+// layer types
+const (
+	dense = "dense"
+	lstm  = "lstm"
+	gru   = "gru"
+)
+
+// optimizers
+const (
+	rmsprop = "rmsprop"
+	adam    = "adam"
+	sgd     = "sgd"
+)
+
+// losses
+const (
+	mae   = "mae"
+	mse   = "mse"
+	huber = "huber"
+)
+
+func init() {
+	config.RootCtx.PersistentFlags().StringSlice(PathSpecLayers, []string{dense, lstm, dense, dense}, "the hidden layers of the production-network in order (each one of: "+dense+", "+lstm+", "+gru+")")
+	config.Viper.BindPFlag(PathSpecLayers, config.RootCtx.PersistentFlags().Lookup(PathSpecLayers))
+
+	config.RootCtx.PersistentFlags().Uint(PathSpecUnits, 32, "the amount of units of each hidden layer of the production-network")
+	config.Viper.BindPFlag(PathSpecUnits, config.RootCtx.PersistentFlags().Lookup(PathSpecUnits))
+
+	config.RootCtx.PersistentFlags().String(PathSpecOptimizer, rmsprop, "the optimizer used for training the production-network (one of: "+rmsprop+", "+adam+", "+sgd+")")
+	config.Viper.BindPFlag(PathSpecOptimizer, config.RootCtx.PersistentFlags().Lookup(PathSpecOptimizer))
+
+	config.RootCtx.PersistentFlags().Float64(PathSpecLearningRate, 0.001, "the learning rate used for training the production-network")
+	config.Viper.BindPFlag(PathSpecLearningRate, config.RootCtx.PersistentFlags().Lookup(PathSpecLearningRate))
+
+	config.RootCtx.PersistentFlags().String(PathSpecLoss, mae, "the loss minimized when training the production-network (one of: "+mae+", "+mse+", "+huber+")")
+	config.Viper.BindPFlag(PathSpecLoss, config.RootCtx.PersistentFlags().Lookup(PathSpecLoss))
+
+	config.RootCtx.PersistentFlags().Uint(PathSpecEpochs, 40, "the amount of epochs the production-network is trained for on each online update")
+	config.Viper.BindPFlag(PathSpecEpochs, config.RootCtx.PersistentFlags().Lookup(PathSpecEpochs))
+
+	config.OnInitialize(func() {
+		spec = Spec{
+			Layers:       config.Viper.GetStringSlice(PathSpecLayers),
+			Units:        config.Viper.GetUint(PathSpecUnits),
+			Optimizer:    config.Viper.GetString(PathSpecOptimizer),
+			LearningRate: config.Viper.GetFloat64(PathSpecLearningRate),
+			Loss:         config.Viper.GetString(PathSpecLoss),
+			Epochs:       config.Viper.GetUint(PathSpecEpochs),
+		}
+		if len(spec.Layers) == 0 {
+			config.InvalidConfiguration(PathSpecLayers, "a non-empty list of layers")
+		}
+		for _, l := range spec.Layers {
+			switch l {
+			case dense, lstm, gru:
+			default:
+				config.InvalidConfiguration(PathSpecLayers, "a list of: "+dense+", "+lstm+", "+gru)
+			}
+		}
+		if spec.Units == 0 {
+			config.InvalidConfiguration(PathSpecUnits, "[1, +inf)")
+		}
+		switch spec.Optimizer {
+		case rmsprop, adam, sgd:
+		default:
+			config.InvalidConfiguration(PathSpecOptimizer, rmsprop+", "+adam+", "+sgd)
+		}
+		if spec.LearningRate <= 0 {
+			config.InvalidConfiguration(PathSpecLearningRate, "(0, +inf)")
+		}
+		switch spec.Loss {
+		case mae, mse, huber:
+		default:
+			config.InvalidConfiguration(PathSpecLoss, mae+", "+mse+", "+huber)
+		}
+		if spec.Epochs == 0 {
+			config.InvalidConfiguration(PathSpecEpochs, "[1, +inf)")
+		}
+	})
+}
+
+// Spec describes the production-network's architecture and how it is trained.
+// Unlike the Schema, it may change without rebuilding the model. Only Layers
+// and Units require a rebuild to take effect.
+type Spec struct {
+	// Layers are the types of the hidden layers in order.
+	Layers []string `json:"layers"`
+	// Units is the amount of units of each hidden layer.
+	Units        uint    `json:"units"`
+	Optimizer    string  `json:"optimizer"`
+	LearningRate float64 `json:"learningRate"`
+	Loss         string  `json:"loss"`
+	// Epochs is the amount of epochs the network is trained for on each online
+	// update.
+	Epochs uint `json:"epochs"`
+}
+
+// spec is the Spec required by the configuration.
+var spec Spec
+
+// encode returns s as JSON, as it is passed to the build-script.
+func (s Spec) encode() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
@@ -20,12 +20,27 @@ const (
 	PathConsideredSteps        = "models.production.consideredsteps"
 	PathMaximumProductionPower = "models.production.maximumpower"
 	PathNormalizationMethod    = "models.production.normalizationmethod"
+	PathForecaster             = "models.production.forecaster"
+	PathHorizon                = "models.production.horizon"
+	PathStrategy               = "models.production.strategy"
 )
 
 // Normalization methods
 const (
 	maxpower   = "maxpower"
 	averageday = "averageday"
+	clearsky   = "clearsky"
+)
+
+// Forecasters
+const (
+	network = "network"
+)
+
+// Multi-step forecasting strategies
+const (
+	recursive = "recursive"
+	direct    = "direct"
 )
 
 func init() {
@@ -44,16 +59,31 @@ func init() {
 	config.RootCtx.PersistentFlags().Float64(PathMaximumProductionPower, 0, "the installed peak-production power (in Watts)")
 	config.Viper.BindPFlag(PathMaximumProductionPower, config.RootCtx.PersistentFlags().Lookup(PathMaximumProductionPower))
 
-	config.RootCtx.PersistentFlags().String(PathNormalizationMethod, maxpower, "the method used for normalizing the power value before passed into the production-model (one of: "+maxpower+", "+averageday+")")
+	config.RootCtx.PersistentFlags().String(PathNormalizationMethod, maxpower, "the method used for normalizing the power value before passed into the production-model (one of: "+maxpower+", "+averageday+", "+clearsky+")")
 	config.Viper.BindPFlag(PathNormalizationMethod, config.RootCtx.PersistentFlags().Lookup(PathNormalizationMethod))
 
+	config.RootCtx.PersistentFlags().String(PathForecaster, network, "the forecaster used for predicting production (one of: "+network+", "+clearsky+")")
+	config.Viper.BindPFlag(PathForecaster, config.RootCtx.PersistentFlags().Lookup(PathForecaster))
+
+	config.RootCtx.PersistentFlags().Duration(PathHorizon, 48*time.Hour, "the maximum duration into the future, that is forecasted (0 for no limit besides the available weather-data)")
+	config.Viper.BindPFlag(PathHorizon, config.RootCtx.PersistentFlags().Lookup(PathHorizon))
+
+	config.RootCtx.PersistentFlags().String(PathStrategy, recursive, "the strategy used for multi-step forecasts (one of: "+recursive+" (each output is fed back as input for the next step), "+direct+" (a multi-output model predicts the whole horizon at once))")
+	config.Viper.BindPFlag(PathStrategy, config.RootCtx.PersistentFlags().Lookup(PathStrategy))
+
 	config.OnInitialize(func() {
 		log = config.NewLogger()
 	})
 
+	config.OnInitialize(func() {
+		if d := config.Viper.GetDuration(PathStepSize); d <= 0 || (24*time.Hour)%d != 0 {
+			config.InvalidConfiguration(PathStepSize, "a duration dividing 24h")
+		}
+	})
+
 	config.OnInitialize(func() {
 		maximumProductionPower = config.Viper.GetFloat64(PathMaximumProductionPower)
-		if config.Viper.GetString(PathNormalizationMethod) == maxpower && maximumProductionPower <= 0 {
+		if (config.Viper.GetString(PathNormalizationMethod) == maxpower || config.Viper.GetString(PathNormalizationMethod) == clearsky || config.Viper.GetString(PathForecaster) == clearsky) && maximumProductionPower <= 0 {
 			config.InvalidConfiguration(PathMaximumProductionPower, "(0, +inf) W")
 		}
 	})
@@ -66,8 +96,33 @@ func init() {
 		case averageday:
 			normalize = normalizeByAvgDay
 			denormalize = denormalizeByAvgDay
+		case clearsky:
+			normalize = normalizeByClearSky
+			denormalize = denormalizeByClearSky
 		default:
-			config.InvalidConfiguration(PathNormalizationMethod, maxpower+", "+averageday)
+			config.InvalidConfiguration(PathNormalizationMethod, maxpower+", "+averageday+", "+clearsky)
+		}
+	})
+
+	config.OnInitialize(func() {
+		switch config.Viper.GetString(PathForecaster) {
+		case network:
+			predict = inference
+		case clearsky:
+			predict = inferenceClearSky
+		default:
+			config.InvalidConfiguration(PathForecaster, network+", "+clearsky)
+		}
+	})
+
+	config.OnInitialize(func() {
+		switch config.Viper.GetString(PathStrategy) {
+		case recursive, direct:
+		default:
+			config.InvalidConfiguration(PathStrategy, recursive+", "+direct)
+		}
+		if config.Viper.GetDuration(PathHorizon) < 0 {
+			config.InvalidConfiguration(PathHorizon, "[0, +inf)")
 		}
 	})
 }
@@ -80,6 +135,8 @@ var (
 
 var normalize, denormalize func(float64, time.Time) float64
 
+var predict func(t time.Time, steps uint)
+
 // Update is the typed equivalence to models.Update for production-updates.
 type Update interface {
 	Data() *Data
@@ -98,59 +155,58 @@ type Update interface {
 type Data struct {
 	// Power holds the average power produced by the system over some duration.
 	Power float64 `csv:"production"`
+	// Curtailed is true if the power was limited by curtailment, e.g. to meet
+	// the site's export limit. Power is only a lower bound of the potential
+	// production then.
+	Curtailed bool `csv:"curtailed"`
+	// Array is the id of the PV-array, that produced the power. It is empty,
+	// if the power was produced by the whole site.
+	Array string `csv:"array"`
 }
 
 var weatherUpdates chan weather.Update
 var incomingProductionUpdates chan Update
 var outgoingProductionUpdates chan Update
 
-var subscribers = make(map[int64]func(Update), 0)
-var sm = &sync.RWMutex{}
+// stopped is closed, once the pipeline stops accepting updates.
+var stopped chan struct{}
 
-// Run starts this model's update-cycle-goroutines.
-func Run(bufferSize uint) {
-	weatherUpdates = make(chan weather.Update, bufferSize)
-	incomingProductionUpdates = make(chan Update, bufferSize)
-	outgoingProductionUpdates = make(chan Update, bufferSize)
+// intake is read-locked while an update is passed to the pipeline. The
+// pipeline write-locks it after closing stopped, so that no update is buffered
+// once the buffers are drained.
+var intake = &sync.RWMutex{}
 
-	// start goroutine, that feeds into the model
-	go func() {
-		for {
-			select {
-			case u := <-incomingProductionUpdates:
-				u.Data().Power = normalize(u.Data().Power, u.Time())
-				handleProductionUpdate(u)
-			case wu := <-weatherUpdates:
-				handleWeatherUpdate(wu)
-			}
-		}
-	}()
-
-	// start goroutine, that updates the subscribers
-	go func() {
-		for {
-			u := <-outgoingProductionUpdates
-			u.Data().Power = denormalize(u.Data().Power, u.Time())
-			notify(u)
-		}
-	}()
-	RunAverage()
-}
+var subscribers = make(map[int64]func(Update), 0)
+var sm = &sync.RWMutex{}
 
 // UpdateWeather receives a update on weather-data. This call may block if the
 // system is overloaded. To prevent this, specify a timeout after with to abort.
+// It returns false, if the pipeline is shutting down.
 func UpdateWeather(update weather.Update, timeout ...time.Duration) (ok bool) {
 	if update != nil {
+		intake.RLock()
+		defer intake.RUnlock()
+		select {
+		case <-stopped:
+			return false
+		default:
+		}
 		if len(timeout) == 1 {
 			select {
 			case weatherUpdates <- update:
 				return true
+			case <-stopped:
+				return false
 			case <-time.After(timeout[0]): // Timeout must not be mocked!
 				return false
 			}
 		} else {
-			weatherUpdates <- update
-			return true
+			select {
+			case weatherUpdates <- update:
+				return true
+			case <-stopped:
+				return false
+			}
 		}
 	}
 	return false
@@ -158,19 +214,32 @@ func UpdateWeather(update weather.Update, timeout ...time.Duration) (ok bool) {
 
 // UpdateProduction receives a update on production-data. This call may block if
 // the system is overloaded. To prevent this, specify a timeout after with to
-// abort.
+// abort. It returns false, if the pipeline is shutting down.
 func UpdateProduction(update Update, timeout ...time.Duration) (ok bool) {
 	if update != nil {
+		intake.RLock()
+		defer intake.RUnlock()
+		select {
+		case <-stopped:
+			return false
+		default:
+		}
 		if len(timeout) == 1 {
 			select {
 			case incomingProductionUpdates <- update:
 				return true
+			case <-stopped:
+				return false
 			case <-time.After(timeout[0]): // Timeout must not be mocked!
 				return false
 			}
 		} else {
-			incomingProductionUpdates <- update
-			return true
+			select {
+			case incomingProductionUpdates <- update:
+				return true
+			case <-stopped:
+				return false
+			}
 		}
 	}
 	return false
@@ -212,11 +281,32 @@ func Round(t time.Time) time.Time {
 	return timeutils.Round(t, config.Viper.GetDuration(PathStepSize))
 }
 
+// StepsPerDay returns the amount of time-steps this model works on per day.
+func StepsPerDay() uint {
+	return uint(24 * time.Hour / config.Viper.GetDuration(PathStepSize))
+}
+
+// StepOfDay returns the index of the time-step t belongs to within its day.
+// The index is derived from the wall-clock time in the site's time zone, so on
+// days with DST-transitions some indices are skipped or occur twice.
+func StepOfDay(t time.Time) uint {
+	r := Round(t).In(location)
+	sinceMidnight := time.Duration(r.Hour())*time.Hour + time.Duration(r.Minute())*time.Minute + time.Duration(r.Second())*time.Second
+	return uint(sinceMidnight/config.Viper.GetDuration(PathStepSize)) % StepsPerDay()
+}
+
 type update struct {
 	data    *Data
 	time    time.Time
 	meta    metadata.Metadata
 	derived bool
+	imputed bool
+	// incomplete is true for the site's partial sum of the arrays
+	incomplete bool
+	// provenance of derived values
+	source string
+	model  metadata.Metadata
+	issued time.Time
 }
 
 func (u *update) Data() *Data {
@@ -236,11 +326,11 @@ func (u *update) IsDerived() bool {
 }
 
 func normalizeByMaxPower(p float64, t time.Time) float64 {
-	return p / maximumProductionPower
+	return p / current.peakPower()
 }
 
 func denormalizeByMaxPower(p float64, t time.Time) float64 {
-	return p * maximumProductionPower
+	return p * current.peakPower()
 }
 
 func normalizeByAvgDay(p float64, t time.Time) float64 {
@@ -248,7 +338,7 @@ func normalizeByAvgDay(p float64, t time.Time) float64 {
 	if p != 0 {
 		n = p
 	}
-	if v, ok := GetNonDerived(0, uint(t.Hour())); ok && v != 0 {
+	if v, ok := averageNonDerived(t); ok && v != 0 {
 		n = v
 	}
 	return p / n
@@ -259,7 +349,7 @@ func denormalizeByAvgDay(p float64, t time.Time) float64 {
 	if p != 0 {
 		n = p
 	}
-	if v, ok := GetNonDerived(0, uint(t.Hour())); ok && v != 0 {
+	if v, ok := averageNonDerived(t); ok && v != 0 {
 		n = v
 	}
 	return p * n
@@ -1,11 +1,9 @@
 package production
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
-	"os"
-	"os/exec"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"time"
@@ -21,11 +19,29 @@ func init() {
 	config.OnInitialize(func() {
 		requiredPreceding = config.Viper.GetUint(PathConsideredSteps)
 		batchSize = config.Viper.GetUint(PathBatchSize)
-		requiredSubsequent = batchSize - 1
 		inferenceBatchSize = config.Viper.GetUint(PathInferenceBatchSize)
-		requiredInferenceSubsequent = inferenceBatchSize - 1
 		stepsize = config.Viper.GetDuration(PathStepSize)
-		maxSize := batchSize
+		horizon = uint(config.Viper.GetDuration(PathHorizon) / stepsize)
+		strategy = config.Viper.GetString(PathStrategy)
+		outputSteps = 1
+		modelPath = filepath.Join(config.Viper.GetString(PathModelDirectory), "production.h5")
+		inferenceScript = "inference_production.py"
+		trainingScript = "training_production.py"
+		if strategy == direct {
+			if horizon == 0 {
+				config.InvalidConfiguration(PathHorizon, "[stepsize, +inf) if "+PathStrategy+" is "+direct)
+			}
+			outputSteps = horizon
+			inferenceBatchSize = horizon
+			modelPath = filepath.Join(config.Viper.GetString(PathModelDirectory), "production_direct.h5")
+			inferenceScript = "inference_production_direct.py"
+			trainingScript = "training_production_direct.py"
+		}
+		if inferenceBatchSize == 0 {
+			config.InvalidConfiguration(PathInferenceBatchSize, "[1, +inf)")
+		}
+		requiredSubsequent = batchSize + outputSteps - 2
+		maxSize := batchSize + outputSteps - 1
 		if inferenceBatchSize > maxSize {
 			maxSize = inferenceBatchSize
 		}
@@ -34,14 +50,12 @@ func init() {
 			Timestamp:  timeutils.Now(),
 			Identifier: 0,
 		}
-		if _, err := os.Stat("./python/production.h5"); os.IsNotExist(err) {
-			log.Info("creating production model...")
-			cmd := exec.Command("python3", "./python/build_model_production.py", "./python/production.h5")
-			out, err := cmd.CombinedOutput()
-			if err != nil {
-				log.WithError(err).WithField("out", string(out)).Fatal("could not create production-model")
-			}
-			log.Debug("production-model created")
+		configureSchema()
+		if config.Viper.GetString(PathForecaster) == clearsky {
+			// the physical model neither requires history nor training
+			requiredPreceding = 0
+			batchSize = 0
+			strategy = recursive
 		}
 	})
 }
@@ -52,17 +66,29 @@ type cupdate struct {
 }
 
 var (
-	stepsize                    time.Duration
-	requiredPreceding           uint
-	batchSize                   uint
-	requiredSubsequent          uint
-	inferenceBatchSize          uint
-	requiredInferenceSubsequent uint
-	outdated                    time.Duration
+	stepsize           time.Duration
+	requiredPreceding  uint
+	batchSize          uint
+	requiredSubsequent uint
+	inferenceBatchSize uint
+	horizon            uint
+	outputSteps        uint
+	strategy           string
+	outdated           time.Duration
+)
+
+var (
+	modelPath       string
+	inferenceScript string
+	trainingScript  string
 )
 
 var cache = make(map[time.Time]*cupdate)
 
+// history holds the steps removed from the cache, as long as they may be read
+// by a feature looking back.
+var history = make(map[time.Time]*cupdate)
+
 var model metadata.Metadata
 
 func handleProductionUpdate(u Update) {
@@ -74,15 +100,7 @@ func handleProductionUpdate(u Update) {
 	}
 	cache[r].p = u
 	log.WithField("id", u.Meta().ID()).WithField("time", u.Time()).WithField("value", u.Data().Power).Trace("sending received update into outgoing channel")
-	// send copy of actual data, so that changes are not reflected inside this file's logic
-	outgoingProductionUpdates <- &update{
-		time: u.Time(),
-		meta: u.Meta(),
-		data: &Data{
-			Power: u.Data().Power,
-		},
-		derived: false,
-	}
+	emit(u)
 	log.Trace("\n" + formatCache(cache))
 	applyUpdates()
 }
@@ -95,7 +113,7 @@ func handleWeatherUpdate(wu weather.Update) {
 		cache[r] = &cupdate{}
 	}
 	// do only apply update if it really contains new information
-	if cache[r].w != nil && weather.Equal(cache[r].w.Data(), wu.Data()) {
+	if cache[r].w != nil && !isImputed(cache[r].w) && weather.Equal(cache[r].w.Data(), wu.Data()) {
 		log.WithField("time", wu.Time()).Trace("dropped duplicate-update")
 		return
 	}
@@ -103,7 +121,15 @@ func handleWeatherUpdate(wu weather.Update) {
 	applyUpdates()
 }
 
+// applyUpdates schedules all training- and inference-jobs, that are possible
+// with the currently cached values of the current series. Inference is
+// postponed while a training-job is pending, as the model is about to change.
+// The sum of the arrays is not forecasted itself.
 func applyUpdates() {
+	if draining || trainingPending || current.isAggregate() {
+		return
+	}
+	impute()
 	timestamps := make([]time.Time, 0, len(cache))
 	for t := range cache {
 		timestamps = append(timestamps, t)
@@ -113,177 +139,412 @@ func applyUpdates() {
 	})
 	log.WithField("cached_amount", len(timestamps)).Trace("applying updates...")
 
-	modelDidChange := true
-
-outer:
-	for modelDidChange {
-		modelDidChange = false
-		log.Trace("checking for new update-possibilities since last iteration...")
-		for i, t := range timestamps {
-			c := cache[t]
-			log.WithField("time", t).WithField("index", i).Trace("checking cached value: ", c)
-			// does this step trigger a model-update?
-			// the production-value does exist, and is newer than the model
-			if batchSize != 0 && c != nil && c.p != nil && c.p.Meta().ID() > model.ID() {
-				log.Trace("step triggers model-update")
-				// can the model be updated?
-				// both values exist for all required preceding and subsequent steps and this one, and there is no gap in the steps
-				if forAllIs(timestamps, func(t time.Time) bool {
-					return fullyExists(cache[t]) && !cache[t].p.IsDerived()
-				}, rngI(i-int(requiredPreceding), i+int(requiredSubsequent))...) && isGapless(timestamps, i-int(requiredPreceding), i+int(requiredSubsequent), stepsize) {
-					log.Trace("step fullfills requirements for model update")
-					modelDidChange = training(t)
-					continue outer
-				}
+	for i, t := range timestamps {
+		c := cache[t]
+		log.WithField("time", t).WithField("index", i).Trace("checking cached value: ", c)
+		// does this step trigger a model-update?
+		// the production-value does exist, and is newer than the model
+		if batchSize != 0 && c != nil && c.p != nil && c.p.Meta().ID() > model.ID() {
+			log.Trace("step triggers model-update")
+			// can the model be updated?
+			// both values exist for all required preceding and subsequent steps and this one, and there is no gap in the steps
+			if forAllIs(timestamps, func(t time.Time) bool {
+				return isTrainable(cache[t])
+			}, rngI(i-int(requiredPreceding), i+int(requiredSubsequent))...) && isGapless(timestamps, i-int(requiredPreceding), i+int(requiredSubsequent), stepsize) {
+				log.Trace("step fullfills requirements for model update")
+				training(t)
+				return
 			}
+		}
 
-			// is this step to be predicted?
-			// the value was not predicted yet, or ((the value was predicted with an older model or from older weather-data) and the value was not provided yet)
-			if c == nil || c.p == nil || ((c.p.Meta().ID() < model.ID() || (c.w != nil && c.p.Meta().ID() < c.w.Meta().ID())) && c.p.IsDerived()) {
-				log.WithField("time", t).Trace("step is to be predicted")
-
-				// can this step be predicted?
-				// the weather-value does exist for this timestamp, and both values exist for all required preceding steps, and there is no gap in the preceding steps
-				if forAllIs(timestamps, func(t time.Time) bool {
-					return fullyExists(cache[t])
-				}, rng(i-int(requiredPreceding), i)...) && forAllIs(timestamps, func(t time.Time) bool {
-					return weatherExists(cache[t]) && (c.p == nil || c.p.IsDerived())
-				}, rngI(i, i+int(requiredInferenceSubsequent))...) && isGapless(timestamps, i-int(requiredPreceding), i+int(requiredInferenceSubsequent), stepsize) {
-					log.Trace("step can be predicted")
-					log.Trace(formatCache(cache))
-					inference(t)
-				}
-			}
+		// is this step being predicted already?
+		if predicting[t] {
+			continue
+		}
+
+		// is this step to be predicted, and can it be predicted?
+		if n := predictableSteps(timestamps, i); n > 0 {
+			log.WithField("time", t).WithField("steps", n).Trace("step can be predicted")
+			log.Trace(formatCache(cache))
+			predict(t, n)
+		} else if n := coldStartableSteps(timestamps, i); n > 0 {
+			log.WithField("time", t).WithField("steps", n).Trace("step can be predicted using cold-start")
+			log.Trace(formatCache(cache))
+			coldStart(t, n)
 		}
 	}
 }
 
-func inference(t time.Time) {
-	log.WithField("time", t).Debug("starting inference...")
-	args := []string{"./python/production.h5"}
-	end := t.Add(time.Duration(requiredInferenceSubsequent-1) * stepsize)
-	for i := t.Add(-1 * time.Duration(requiredPreceding) * stepsize); i.Sub(t) < 0; i = i.Add(stepsize) {
-		args = append(args, formatProduction(cache[i].p.Data())...)
+// predictableSteps returns the amount of steps starting at timestamps[i], that
+// are to be predicted and can be predicted in a single inference process.
+func predictableSteps(timestamps []time.Time, i int) uint {
+	if strategy == direct {
+		return directlyPredictableSteps(timestamps, i)
+	}
+
+	// is this step to be predicted?
+	c := cache[timestamps[i]]
+	improve := !toBePredicted(c) && isColdStarted(c)
+	if !toBePredicted(c) && !improve {
+		return 0
+	}
+
+	// can this step be predicted?
+	// both values exist for all required preceding steps, none of them is being
+	// predicted, and there is no gap in the preceding steps
+	if !forAllIs(timestamps, func(t time.Time) bool {
+		return fullyExists(cache[t]) && !predicting[t]
+	}, rng(i-int(requiredPreceding), i)...) || !isGapless(timestamps, i-int(requiredPreceding), i, stepsize) {
+		return 0
+	}
+
+	// a cold-started value is only replaced, if the prediction is based on
+	// reliable values
+	if improve && !forAllIs(timestamps, func(t time.Time) bool {
+		return metadata.ConfidenceOf(cache[t].p.Meta()) == metadata.High
+	}, rng(i-int(requiredPreceding), i)...) {
+		return 0
 	}
+
+	return subsequentSteps(timestamps, i)
+}
+
+// subsequentSteps returns the amount of steps starting at timestamps[i], that
+// can be predicted in a single inference process, if the preceding steps are
+// available.
+func subsequentSteps(timestamps []time.Time, i int) uint {
+	// the weather-value does exist, the production-value was not provided, the
+	// step is not being predicted, the step is within the forecasting-horizon,
+	// and there is no gap
+	n := uint(0)
+	for j := i; j < len(timestamps) && n < inferenceBatchSize; j++ {
+		c := cache[timestamps[j]]
+		if !weatherExists(c) || predicting[timestamps[j]] || (c.p != nil && !c.p.IsDerived()) || !withinHorizon(timestamps[j]) || !isGapless(timestamps, i, j, stepsize) {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// directlyPredictableSteps is the equivalent of predictableSteps for
+// multi-output models. Those predict the whole horizon at once, starting at
+// the first step after the latest provided production-values.
+func directlyPredictableSteps(timestamps []time.Time, i int) uint {
+	window := rng(i, i+int(outputSteps))
+
+	// is any step of the horizon being predicted already?
+	if existsIs(timestamps, func(t time.Time) bool {
+		return predicting[t]
+	}, window...) {
+		return 0
+	}
+
+	// is any step of the horizon to be predicted?
+	if !existsIs(timestamps, func(t time.Time) bool {
+		return toBePredicted(cache[t]) || isColdStarted(cache[t])
+	}, window...) {
+		return 0
+	}
+
+	// can the horizon be predicted?
+	// both values were provided for all required preceding steps, the
+	// weather-value does exist and the production-value was not provided for
+	// all steps in the horizon, and there is no gap
+	if forAllIs(timestamps, func(t time.Time) bool {
+		return fullyExists(cache[t]) && !cache[t].p.IsDerived()
+	}, rng(i-int(requiredPreceding), i)...) && forAllIs(timestamps, func(t time.Time) bool {
+		return weatherExists(cache[t]) && (cache[t].p == nil || cache[t].p.IsDerived())
+	}, window...) && isGapless(timestamps, i-int(requiredPreceding), i+int(outputSteps)-1, stepsize) {
+		return outputSteps
+	}
+	return 0
+}
+
+// toBePredicted returns true if the value was not predicted yet, or ((the
+// value was predicted with an older model or from older weather-data) and the
+// value was not provided yet).
+func toBePredicted(c *cupdate) bool {
+	return c == nil || c.p == nil || ((c.p.Meta().ID() < model.ID() || (c.w != nil && c.p.Meta().ID() < c.w.Meta().ID())) && c.p.IsDerived())
+}
+
+func withinHorizon(t time.Time) bool {
+	return horizon == 0 || t.Sub(Round(timeutils.Now())) <= time.Duration(horizon)*stepsize
+}
+
+// inferenceJob predicts a batch of steps of a series using the
+// production-model.
+type inferenceJob struct {
+	series  *series
+	t       time.Time
+	steps   uint
+	request modelRequest
+	// metas holds the metadata of each step's inputs
+	metas []metadata.Metadata
+	// predictions based on unreliable values are unreliable as well
+	reliable bool
+	model    metadata.Metadata
+}
+
+type inferenceResult struct {
+	*inferenceJob
+	output []float64
+	err    error
+}
+
+// inference queues an inference-job predicting the given amount of steps
+// starting at t.
+func inference(t time.Time, steps uint) {
+	log.WithField("time", t).WithField("steps", steps).Debug("queueing inference...")
+	// the multi-output model requires the steps to be predicted as input,
+	// whereas the recursive model only requires the steps preceding each one
+	end := t.Add(time.Duration(int(steps)-2) * stepsize)
+	if strategy == direct {
+		end = t.Add(time.Duration(steps-1) * stepsize)
+	}
+	inputs := make([][]float64, 0)
 	for i := t.Add(-1 * time.Duration(requiredPreceding) * stepsize); end.Sub(i) >= 0; i = i.Add(stepsize) {
-		args = append(args, formatTime(i)...)
-		args = append(args, formatWeather(cache[i].w.Data())...)
+		inputs = append(inputs, row(i, i.Before(t)))
 	}
 
-	log.Trace("calling python")
-	cmd := exec.Command("python3", append([]string{"./python/inference_production.py"}, args...)...)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		log.WithError(err).WithField("out", string(out)).WithField("cmd", cmd.String()).Error("inference on production model failed")
-		return
+	reliable := true
+	for i := t.Add(-1 * time.Duration(requiredPreceding) * stepsize); i.Sub(t) < 0; i = i.Add(stepsize) {
+		if metadata.ConfidenceOf(cache[i].p.Meta()) != metadata.High {
+			reliable = false
+		}
 	}
 
-	var output []float64
-	elems := bytes.Split(bytes.TrimSpace(out), []byte("Model output:\n"))
-	fout := []byte{}
-	if len(elems) == 2 {
-		fout = bytes.ReplaceAll(bytes.ReplaceAll(bytes.ReplaceAll(bytes.ReplaceAll(elems[1], []byte{' '}, []byte{}), []byte{'0', '.', ','}, []byte{'0', '.', '0', ','}), []byte{'0', '.', ']'}, []byte{'0', '.', '0', ']'}), []byte{',', ','}, []byte{','})
-		err = json.Unmarshal(fout, &output)
-	} else {
-		err = errors.New("inference-ouput does not contain result-section")
+	metas := make([]metadata.Metadata, 0, steps)
+	for i := uint(0); i < steps; i++ {
+		metas = append(metas, cache[t.Add(time.Duration(i)*stepsize)].w.Meta())
 	}
+
+	enqueueInference(&inferenceJob{
+		series: current,
+		t:      t,
+		steps:  steps,
+		request: modelRequest{
+			Inputs:  inputs,
+			Outputs: steps,
+		},
+		metas:    metas,
+		reliable: reliable,
+		model:    model,
+	})
+}
+
+func (j *inferenceJob) run(ctx context.Context) result {
+	r := &inferenceResult{inferenceJob: j}
+	// the circuit breaker is asked right before running, so that a trial is
+	// not held by a job waiting in the queue
+	if !inferenceBreaker.Allow(timeutils.Now()) {
+		r.err = errUnavailable
+		return r
+	}
+	modelLock.RLock()
+	defer modelLock.RUnlock()
+
+	log.WithField("time", j.t).WithField("steps", j.steps).Debug("starting inference...")
+	res, out, err := call(ctx, inferenceTimeout, inferenceScript, j.request)
 	if err != nil {
-		if len(elems) == 2 {
-			log.WithError(err).WithField("out", string(fout)).Error("inference on production model failed")
-		} else {
-			log.WithError(err).WithField("out", string(out)).Error("inference on production model failed")
-		}
-		return
+		log.WithError(err).WithField("out", string(out)).Error("inference on production model failed")
+		r.err = err
+		return r
+	}
+	r.output = res.Output
+
+	log.WithField("output", r.output).Trace("call to python completed")
+
+	if len(r.output) > int(j.steps) {
+		r.output = r.output[:j.steps]
 	}
+	return r
+}
 
-	log.WithField("output", output).Trace("call to python completed")
+func (r *inferenceResult) apply() {
+	within(r.series, r.store)
+	sumForecasts()
+}
 
-	if cache[t] == nil {
-		cache[t] = &cupdate{}
+// store caches the predicted values. Steps, whose production-value was
+// provided or which became outdated meanwhile, are skipped.
+func (r *inferenceResult) store() {
+	for i := uint(0); i < r.steps; i++ {
+		delete(predicting, r.t.Add(time.Duration(i)*stepsize))
 	}
+	if r.err != nil {
+		reportInference(r.err)
+		switch {
+		case errors.Is(r.err, context.Canceled):
+		case r.err == errUnavailable:
+			log.WithField("time", r.t).WithField("steps", r.steps).Debug("production-model is unavailable, using fallback...")
+			fallback(r.t, r.steps)
+		case isRejection(r.err):
+			// predicting again would be rejected again
+			fallback(r.t, r.steps)
+		default:
+			// the steps are predicted again right away, until the circuit
+			// breaker opens and the fallback-forecaster takes over
+			applyUpdates()
+		}
+		return
+	}
+	reportInference(nil)
 
-	for i := range output {
-		t := t.Add(time.Duration(i) * stepsize)
-		m := latest(model, cache[t].w.Meta())
-		if i > 0 {
-			m = latest(m, cache[t.Add(-1*stepsize)].p.Meta())
+	var m metadata.Metadata
+	for i := range r.output {
+		t := r.t.Add(time.Duration(i) * stepsize)
+		if i == 0 {
+			m = latest(r.model, r.metas[i])
+		} else {
+			m = latest(m, r.metas[i])
 		}
-		cache[t].p = &update{
+		if !r.reliable && metadata.ConfidenceOf(m) == metadata.High {
+			m = &metadata.Annotated{
+				Metadata: m,
+				Quality:  metadata.Low,
+				Reason:   reasonColdStart,
+			}
+		}
+		c := cache[t]
+		if !weatherExists(c) || (c.p != nil && !c.p.IsDerived()) {
+			continue
+		}
+		c.p = &update{
 			data: &Data{
-				Power: output[i],
+				Power: r.output[i],
 			},
 			time:    t,
 			meta:    m,
 			derived: true,
+			source:  SourceNetwork,
+			model:   r.model,
+			issued:  timeutils.Now(),
 		}
-		log.WithField("id", cache[t].p.Meta().ID()).WithField("time", cache[t].p.Time()).WithField("value", cache[t].p.Data().Power).WithField("id", cache[t].p.Meta().ID()).Trace("sending update into outgoing channel")
-		outgoingProductionUpdates <- cache[t].p
+		log.WithField("id", c.p.Meta().ID()).WithField("time", c.p.Time()).WithField("value", c.p.Data().Power).Trace("sending update into outgoing channel")
+		emit(c.p)
 	}
 
 	log.Debug("predicted production-values")
+	applyUpdates()
+}
+
+// trainingJob updates the production-model.
+type trainingJob struct {
+	t       time.Time
+	request modelRequest
+	// latest is the metadata of the latest value the model is trained with
+	latest metadata.Metadata
 }
 
-func training(t time.Time) (ok bool) {
-	log.WithField("time", t).Debug("starting training...")
+type trainingResult struct {
+	*trainingJob
+	err error
+}
+
+// training queues a training-job using the batch starting at t.
+func training(t time.Time) {
+	log.WithField("time", t).Debug("queueing training...")
 	latest := model
 
-	args := []string{"./python/production.h5"}
-	end := t.Add(time.Duration(requiredSubsequent) * stepsize)
+	samples := make([]sample, 0, batchSize)
+	end := t.Add(time.Duration(batchSize-1) * stepsize)
 	for i := t; end.Sub(i) >= 0; i = i.Add(stepsize) {
-		for j := i.Add(-1 * time.Duration(requiredPreceding) * stepsize); i.Sub(j) > 0; j = j.Add(stepsize) {
-			if cache[j].w.Meta().ID() > latest.ID() {
-				latest = cache[j].w.Meta()
-			}
-			if cache[j].p.Meta().ID() > latest.ID() {
-				latest = cache[j].p.Meta()
-			}
-			args = append(args, formatTime(j)...)
-			args = append(args, formatProduction(cache[j].p.Data())...)
-			args = append(args, formatWeather(cache[j].w.Data())...)
-		}
-		args = append(args, formatProduction(cache[i].p.Data())...)
+		var s sample
+		s, latest = newSample(i, latest)
+		samples = append(samples, s)
 	}
 
-	log.Trace("calling python")
-	cmd := exec.Command("python3", append([]string{"./python/training_production.py"}, args...)...)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		log.WithError(err).WithField("out", string(out)).WithField("cmd", cmd.String()).Error("training on production model failed")
-		return false
+	enqueueTraining(&trainingJob{
+		t:       t,
+		request: modelRequest{Samples: samples, Spec: &spec},
+		latest:  latest,
+	})
+}
+
+// newSample returns the training-sample, whose first target is the step at i.
+// latest is replaced by the newest metadata of the sample's inputs.
+func newSample(i time.Time, latest metadata.Metadata) (sample, metadata.Metadata) {
+	s, latest := newInputs(i, latest)
+	// the sample's targets, each marked as censored (i.e. a lower bound)
+	// due to curtailment
+	for j := i; j.Sub(i) < time.Duration(outputSteps)*stepsize; j = j.Add(stepsize) {
+		s.Targets = append(s.Targets, cache[j].p.Data().Power)
+		s.Censored = append(s.Censored, isCensored(cache[j]))
 	}
-	model = latest
-	log.WithField("model", model).Trace("model updated")
-	log.WithField("id", model.ID()).WithField("time", t).Debug("updated production-model")
-	return true
+	return s, latest
 }
 
-func formatProduction(p *Data) []string {
-	if p == nil {
-		return []string{}
+// newInputs returns the sample, whose first target is the step at i, without
+// its targets. latest is replaced by the newest metadata of the sample's
+// inputs.
+func newInputs(i time.Time, latest metadata.Metadata) (sample, metadata.Metadata) {
+	// the sample's input consists of the preceding steps and, for
+	// multi-output models, of the steps to be predicted
+	last := i.Add(time.Duration(outputSteps-1) * stepsize)
+	if strategy != direct {
+		last = i.Add(-1 * stepsize)
+	}
+	s := sample{}
+	for j := i.Add(-1 * time.Duration(requiredPreceding) * stepsize); last.Sub(j) >= 0; j = j.Add(stepsize) {
+		if cache[j].w.Meta().ID() > latest.ID() {
+			latest = cache[j].w.Meta()
+		}
+		if cache[j].p != nil && cache[j].p.Meta().ID() > latest.ID() {
+			latest = cache[j].p.Meta()
+		}
+		s.Inputs = append(s.Inputs, row(j, j.Before(i)))
 	}
-	return []string{ff(p.Power)}
+	return s, latest
 }
 
-func formatWeather(w *weather.Data) []string {
-	if w == nil {
-		return []string{}
+func (j *trainingJob) run(ctx context.Context) result {
+	modelLock.Lock()
+	defer modelLock.Unlock()
+
+	log.WithField("time", j.t).Debug("starting training...")
+	_, out, err := call(ctx, trainingTimeout, trainingScript, j.request)
+	if err != nil {
+		log.WithError(err).WithField("out", string(out)).Error("training on production model failed")
 	}
-	return []string{ff(w.CloudCover), ff(w.PrecipitationProbability), ff(w.WindSpeed), ff(w.WindGust), ff(w.PrecipitationIntensity), ff(w.ApparentTemperature), ff(w.Humidity), ff(w.DewPoint), ff(w.Visibility), ff(w.UVIndex), ff(w.Temperature)}
+	return &trainingResult{trainingJob: j, err: err}
 }
 
-func formatTime(t time.Time) []string {
-	return []string{ff(timeutils.YearProcess(t)), ff(timeutils.DayProcess(t))}
+// apply replaces the model's metadata and schedules all jobs of all series,
+// that were postponed during training.
+func (r *trainingResult) apply() {
+	trainingPending = false
+	if r.err != nil {
+		// training is retried with the next update
+		return
+	}
+	model = r.latest
+	log.WithField("model", model).Trace("model updated")
+	log.WithField("id", model.ID()).WithField("time", r.t).Debug("updated production-model")
+	eachSeries(applyUpdates)
+	sumForecasts()
 }
 
 func ff(f float64) string {
 	return strconv.FormatFloat(f, 'f', 6, 64)
 }
 
+// clearOutdatedCache removes outdated steps from the cache. Steps, that may
+// still be read by a feature looking back, are moved to the history.
 func clearOutdatedCache() {
 	before := len(cache)
-	for t := range cache {
-		if timeutils.Now().Sub(t) >= outdated {
+	now := timeutils.Now()
+	for t, c := range cache {
+		if now.Sub(t) >= outdated {
 			delete(cache, t)
+			if lookback > 0 {
+				history[t] = c
+			}
+		}
+	}
+	for t := range history {
+		if now.Sub(t) >= outdated+time.Duration(lookback)*stepsize {
+			delete(history, t)
 		}
 	}
 	if before > len(cache) {
@@ -291,6 +552,14 @@ func clearOutdatedCache() {
 	}
 }
 
+// lookup returns the step at t from the cache or the history.
+func lookup(t time.Time) *cupdate {
+	if c := cache[t]; c != nil {
+		return c
+	}
+	return history[t]
+}
+
 func fullyExists(c *cupdate) bool {
 	return c != nil && c.p != nil && c.w != nil
 }
@@ -334,6 +603,15 @@ func forAllIs(timeline []time.Time, condition func(time.Time) bool, indices ...i
 	return true
 }
 
+func existsIs(timeline []time.Time, condition func(time.Time) bool, indices ...int) bool {
+	for _, i := range indices {
+		if i >= 0 && i < len(timeline) && condition(timeline[i]) {
+			return true
+		}
+	}
+	return false
+}
+
 func formatCache(cache map[time.Time]*cupdate) string {
 	s := "time\t\t\t\t | ahead\t\t\t\t | production\t\t\t\t | weather\t\t\t\t | prodDerived\n========================================================================================================================================\n"
 
@@ -358,14 +636,14 @@ func formatCache(cache map[time.Time]*cupdate) string {
 			if c.p == nil {
 				s += "nil\t\t\t\t | "
 			} else {
-				s += ff(c.p.Data().Power) + " (" + strconv.Itoa(int(c.p.Meta().ID())) + ")" + "\t\t\t\t | "
+				s += ff(c.p.Data().Power) + " (" + strconv.Itoa(int(c.p.Meta().ID())) + ")" + imputedMark(c.p) + "\t\t\t\t | "
 			}
 			if c.w == nil {
 				s += "nil\t\t\t\t"
 			} else {
 				// b, _ := json.Marshal(c.w.Data())
 
-				s += "some" + " (" + strconv.Itoa(int(c.w.Meta().ID())) + ")" + "\t\t\t\t"
+				s += "some" + " (" + strconv.Itoa(int(c.w.Meta().ID())) + ")" + imputedMark(c.w) + "\t\t\t\t"
 			}
 			if c.p != nil && c.p.IsDerived() {
 				s += "true\n"
@@ -377,6 +655,13 @@ func formatCache(cache map[time.Time]*cupdate) string {
 	return s
 }
 
+func imputedMark(u interface{}) string {
+	if isImputed(u) {
+		return " imputed"
+	}
+	return ""
+}
+
 func latest(first metadata.Metadata, others ...metadata.Metadata) metadata.Metadata {
 	latest := first
 	for _, o := range others {
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"time"
 
 	"github.com/theMomax/openefs/config"
@@ -30,7 +31,9 @@ type Update interface {
 	Meta() metadata.Metadata
 }
 
-// Run parametrizes and starts the update-cycle-goroutines of all subpackages.
-func Run() {
-	production.Run(config.Viper.GetUint(PathBufferSize))
+// Run parametrizes and starts the pipelines of all subpackages. The pipelines
+// shut down once ctx is done. The returned channel is closed when all of them
+// have stopped.
+func Run(ctx context.Context) <-chan struct{} {
+	return production.Run(ctx, config.Viper.GetUint(PathBufferSize))
 }
@@ -0,0 +1,289 @@
+package dispatch
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/theMomax/openefs/cache/price"
+	cache "github.com/theMomax/openefs/cache/production"
+	"github.com/theMomax/openefs/config"
+	models "github.com/theMomax/openefs/models/production"
+	timeutils "github.com/theMomax/openefs/utils/time"
+)
+
+// Config paths
+const (
+	PathCapacity            = "optimization.dispatch.battery.capacity"
+	PathMaxCharge           = "optimization.dispatch.battery.maxcharge"
+	PathMaxDischarge        = "optimization.dispatch.battery.maxdischarge"
+	PathChargeEfficiency    = "optimization.dispatch.battery.chargeefficiency"
+	PathDischargeEfficiency = "optimization.dispatch.battery.dischargeefficiency"
+	PathMinSoC              = "optimization.dispatch.battery.minsoc"
+	PathMaxSoC              = "optimization.dispatch.battery.maxsoc"
+	PathSoC                 = "optimization.dispatch.battery.soc"
+	PathLoad                = "optimization.dispatch.load"
+	PathImportPrice         = "optimization.dispatch.tariff.import"
+	PathExportPrice         = "optimization.dispatch.tariff.export"
+	PathLevels              = "optimization.dispatch.levels"
+	PathDebounce            = "optimization.dispatch.debounce"
+)
+
+func init() {
+	config.RootCtx.PersistentFlags().Float64(PathCapacity, 0, "the usable capacity of the battery (in Wh); 0 disables dispatch-planning")
+	config.Viper.BindPFlag(PathCapacity, config.RootCtx.PersistentFlags().Lookup(PathCapacity))
+
+	config.RootCtx.PersistentFlags().Float64(PathMaxCharge, 0, "the maximum charging power of the battery (in W)")
+	config.Viper.BindPFlag(PathMaxCharge, config.RootCtx.PersistentFlags().Lookup(PathMaxCharge))
+
+	config.RootCtx.PersistentFlags().Float64(PathMaxDischarge, 0, "the maximum discharging power of the battery (in W)")
+	config.Viper.BindPFlag(PathMaxDischarge, config.RootCtx.PersistentFlags().Lookup(PathMaxDischarge))
+
+	config.RootCtx.PersistentFlags().Float64(PathChargeEfficiency, 0.95, "the fraction of the power drawn while charging, that is stored")
+	config.Viper.BindPFlag(PathChargeEfficiency, config.RootCtx.PersistentFlags().Lookup(PathChargeEfficiency))
+
+	config.RootCtx.PersistentFlags().Float64(PathDischargeEfficiency, 0.95, "the fraction of the stored power, that is delivered while discharging")
+	config.Viper.BindPFlag(PathDischargeEfficiency, config.RootCtx.PersistentFlags().Lookup(PathDischargeEfficiency))
+
+	config.RootCtx.PersistentFlags().Float64(PathMinSoC, 0.1, "the minimum state of charge of the battery (0 to 1)")
+	config.Viper.BindPFlag(PathMinSoC, config.RootCtx.PersistentFlags().Lookup(PathMinSoC))
+
+	config.RootCtx.PersistentFlags().Float64(PathMaxSoC, 1, "the maximum state of charge of the battery (0 to 1)")
+	config.Viper.BindPFlag(PathMaxSoC, config.RootCtx.PersistentFlags().Lookup(PathMaxSoC))
+
+	config.RootCtx.PersistentFlags().Float64(PathSoC, 0.5, "the initial state of charge of the battery (0 to 1)")
+	config.Viper.BindPFlag(PathSoC, config.RootCtx.PersistentFlags().Lookup(PathSoC))
+
+	config.RootCtx.PersistentFlags().StringSlice(PathLoad, []string{"0"}, "the daily consumption-profile (in W) as a list of values spread evenly over the day")
+	config.Viper.BindPFlag(PathLoad, config.RootCtx.PersistentFlags().Lookup(PathLoad))
+
+	config.RootCtx.PersistentFlags().StringSlice(PathImportPrice, []string{"0.3"}, "the daily price-profile for importing energy (per kWh) as a list of values spread evenly over the day")
+	config.Viper.BindPFlag(PathImportPrice, config.RootCtx.PersistentFlags().Lookup(PathImportPrice))
+
+	config.RootCtx.PersistentFlags().StringSlice(PathExportPrice, []string{"0.08"}, "the daily revenue-profile for exporting energy (per kWh) as a list of values spread evenly over the day")
+	config.Viper.BindPFlag(PathExportPrice, config.RootCtx.PersistentFlags().Lookup(PathExportPrice))
+
+	config.RootCtx.PersistentFlags().Uint(PathLevels, 101, "the amount of discrete state-of-charge-levels considered by the optimizer")
+	config.Viper.BindPFlag(PathLevels, config.RootCtx.PersistentFlags().Lookup(PathLevels))
+
+	config.RootCtx.PersistentFlags().Duration(PathDebounce, time.Second, "the duration to wait for further forecast-changes before re-planning")
+	config.Viper.BindPFlag(PathDebounce, config.RootCtx.PersistentFlags().Lookup(PathDebounce))
+
+	config.OnInitialize(func() {
+		settings.Battery = Battery{
+			Capacity:            config.Viper.GetFloat64(PathCapacity),
+			MaxCharge:           config.Viper.GetFloat64(PathMaxCharge),
+			MaxDischarge:        config.Viper.GetFloat64(PathMaxDischarge),
+			ChargeEfficiency:    config.Viper.GetFloat64(PathChargeEfficiency),
+			DischargeEfficiency: config.Viper.GetFloat64(PathDischargeEfficiency),
+			MinSoC:              config.Viper.GetFloat64(PathMinSoC),
+			MaxSoC:              config.Viper.GetFloat64(PathMaxSoC),
+			SoC:                 config.Viper.GetFloat64(PathSoC),
+		}
+		if settings.Battery.Capacity != 0 && settings.Battery.Validate() != nil {
+			config.InvalidConfiguration("optimization.dispatch.battery", "consistent battery-parameters")
+		}
+
+		var err error
+		if settings.Load, err = parseProfile(config.Viper.GetStringSlice(PathLoad)); err != nil {
+			config.InvalidConfiguration(PathLoad, "a non-empty list of numbers")
+		}
+		if settings.Tariff.Import, err = parseProfile(config.Viper.GetStringSlice(PathImportPrice)); err != nil {
+			config.InvalidConfiguration(PathImportPrice, "a non-empty list of numbers")
+		}
+		if settings.Tariff.Export, err = parseProfile(config.Viper.GetStringSlice(PathExportPrice)); err != nil {
+			config.InvalidConfiguration(PathExportPrice, "a non-empty list of numbers")
+		}
+
+		levels = int(config.Viper.GetUint(PathLevels))
+		if levels < 2 {
+			config.InvalidConfiguration(PathLevels, "[2, +inf)")
+		}
+		debounce = config.Viper.GetDuration(PathDebounce)
+		stepSize = config.Viper.GetDuration(models.PathStepSize)
+	})
+
+	config.OnInitialize(func() {
+		log = config.NewLogger()
+	})
+}
+
+var log *logrus.Logger
+
+// Profile is a daily profile. Its values are spread evenly over the day, i.e.
+// a Profile of length 24 holds hourly values.
+type Profile []float64
+
+// At returns the profile's value at time t in the site's time zone.
+func (p Profile) At(t time.Time) float64 {
+	if len(p) == 0 {
+		return 0
+	}
+	midnight := models.Midnight(t)
+	i := int(t.Sub(midnight) * time.Duration(len(p)) / (24 * time.Hour))
+	if i >= len(p) {
+		i = len(p) - 1
+	}
+	return p[i]
+}
+
+// Tariff holds the prices for exchanging energy with the grid. It is used for
+// all steps, for which no dynamic prices were provided.
+type Tariff struct {
+	Import Profile `json:"import"`
+	Export Profile `json:"export"`
+}
+
+// Settings holds all parameters required for dispatch-planning.
+type Settings struct {
+	Battery Battery `json:"battery"`
+	// Load is the expected consumption in W.
+	Load   Profile `json:"load"`
+	Tariff Tariff  `json:"tariff"`
+}
+
+// ErrInvalidProfile is returned if a Profile is empty.
+var ErrInvalidProfile = errors.New("profiles must contain at least one value")
+
+// Validate returns an error if s is unusable for dispatch-planning.
+func (s Settings) Validate() error {
+	if err := s.Battery.Validate(); err != nil {
+		return err
+	}
+	if len(s.Load) == 0 || len(s.Tariff.Import) == 0 || len(s.Tariff.Export) == 0 {
+		return ErrInvalidProfile
+	}
+	return nil
+}
+
+var (
+	levels   int
+	debounce time.Duration
+	stepSize time.Duration
+)
+
+var (
+	settings Settings
+	plan     *Plan
+	m        = &sync.RWMutex{}
+)
+
+var replan = make(chan struct{}, 1)
+
+// Run subscribes to the production-cache and keeps the dispatch-plan up to
+// date.
+func Run() {
+	cache.Subscribe(func(models.Update) {
+		trigger()
+	}, nil, nil)
+	price.Subscribe(func(time.Time, price.Data) {
+		trigger()
+	})
+
+	go func() {
+		for range replan {
+			timeutils.Sleep(debounce)
+			// drain triggers received while waiting
+			select {
+			case <-replan:
+			default:
+			}
+			update()
+		}
+	}()
+}
+
+// Configure replaces the current settings and returns the resulting plan. The
+// plan is nil if no production-forecast is available.
+func Configure(s Settings) (*Plan, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	m.Lock()
+	settings = s
+	m.Unlock()
+	return update(), nil
+}
+
+// CurrentSettings returns the settings currently used for planning.
+func CurrentSettings() Settings {
+	m.RLock()
+	defer m.RUnlock()
+	return settings
+}
+
+// CurrentPlan returns the latest dispatch-plan or nil if there is none.
+func CurrentPlan() *Plan {
+	m.RLock()
+	defer m.RUnlock()
+	return plan
+}
+
+func trigger() {
+	select {
+	case replan <- struct{}{}:
+	default:
+	}
+}
+
+// update computes a new plan from the current settings and forecasts.
+func update() *Plan {
+	s := CurrentSettings()
+	if s.Battery.Capacity == 0 {
+		return nil
+	}
+
+	var steps []Step
+	for t := models.Round(timeutils.Now()); ; t = t.Add(stepSize) {
+		u := cache.Update(t)
+		if u == nil || u.Data() == nil {
+			break
+		}
+		step := Step{
+			Time:        t,
+			Production:  u.Data().Power,
+			Load:        s.Load.At(t),
+			ImportPrice: s.Tariff.Import.At(t),
+			ExportPrice: s.Tariff.Export.At(t),
+			ExportLimit: models.ExportLimit(),
+		}
+		// dynamic prices take precedence over the tariff
+		if p := price.Get(t); p != nil {
+			step.ImportPrice = p.Import
+			step.ExportPrice = p.Export
+		}
+		steps = append(steps, step)
+	}
+
+	var p *Plan
+	if len(steps) > 0 {
+		o := Optimize(s.Battery, steps, stepSize, levels)
+		o.Created = timeutils.Now()
+		p = &o
+	}
+
+	m.Lock()
+	plan = p
+	m.Unlock()
+	if p != nil {
+		log.WithField("steps", len(p.Actions)).WithField("cost", p.Cost).Debug("updated dispatch-plan")
+	}
+	return p
+}
+
+func parseProfile(values []string) (Profile, error) {
+	if len(values) == 0 {
+		return nil, ErrInvalidProfile
+	}
+	p := make(Profile, len(values))
+	for i, v := range values {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, err
+		}
+		p[i] = f
+	}
+	return p, nil
+}
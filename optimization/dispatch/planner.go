@@ -0,0 +1,200 @@
+package dispatch
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// Battery describes a home-battery and its current state.
+type Battery struct {
+	// Capacity is the usable capacity in Wh.
+	Capacity float64 `json:"capacity"`
+	// MaxCharge is the maximum charging power in W.
+	MaxCharge float64 `json:"maxCharge"`
+	// MaxDischarge is the maximum discharging power in W.
+	MaxDischarge float64 `json:"maxDischarge"`
+	// ChargeEfficiency is the fraction (0 to 1] of the power drawn while
+	// charging, that is stored.
+	ChargeEfficiency float64 `json:"chargeEfficiency"`
+	// DischargeEfficiency is the fraction (0 to 1] of the stored power, that
+	// is delivered while discharging.
+	DischargeEfficiency float64 `json:"dischargeEfficiency"`
+	// MinSoC and MaxSoC limit the state of charge (0 to 1).
+	MinSoC float64 `json:"minSoC"`
+	MaxSoC float64 `json:"maxSoC"`
+	// SoC is the current state of charge (0 to 1).
+	SoC float64 `json:"soc"`
+}
+
+// Step describes the situation the battery is dispatched in during a single
+// time-step.
+type Step struct {
+	Time time.Time `json:"time"`
+	// Production is the average PV-production in W.
+	Production float64 `json:"production"`
+	// Load is the average consumption in W.
+	Load float64 `json:"load"`
+	// ImportPrice is the price for buying energy from the grid per kWh.
+	ImportPrice float64 `json:"importPrice"`
+	// ExportPrice is the revenue for feeding energy into the grid per kWh.
+	ExportPrice float64 `json:"exportPrice"`
+	// ExportLimit is the maximum power in W, that may be fed into the grid.
+	// Surplus beyond it is curtailed. Use math.Inf(1) for no limit.
+	ExportLimit float64 `json:"-"`
+}
+
+// Action is the recommended dispatch for a single time-step.
+type Action struct {
+	Time time.Time `json:"time"`
+	// Battery is the average power flowing into the battery in W. It is
+	// negative while discharging.
+	Battery float64 `json:"battery"`
+	// SoC is the state of charge at the end of the step.
+	SoC float64 `json:"soc"`
+	// GridImport and GridExport are the average power exchanged with the grid
+	// in W.
+	GridImport float64 `json:"gridImport"`
+	GridExport float64 `json:"gridExport"`
+	// Curtailed is the average surplus power in W, that could neither be
+	// stored nor exported.
+	Curtailed float64 `json:"curtailed"`
+	// Cost is the cost of the step's grid-exchange. It is negative if the
+	// revenue exceeds the expenses.
+	Cost float64 `json:"cost"`
+}
+
+// Plan is a dispatch-plan over several time-steps.
+type Plan struct {
+	Created time.Time `json:"created"`
+	Actions []Action  `json:"actions"`
+	// Cost is the total cost of all actions.
+	Cost float64 `json:"cost"`
+}
+
+// ErrInvalidBattery is returned if a Battery's parameters are inconsistent.
+var ErrInvalidBattery = errors.New("battery parameters are invalid")
+
+// Validate returns ErrInvalidBattery if b's parameters are inconsistent.
+func (b Battery) Validate() error {
+	if b.Capacity <= 0 || b.MaxCharge < 0 || b.MaxDischarge < 0 ||
+		b.ChargeEfficiency <= 0 || b.ChargeEfficiency > 1 ||
+		b.DischargeEfficiency <= 0 || b.DischargeEfficiency > 1 ||
+		b.MinSoC < 0 || b.MaxSoC > 1 || b.MinSoC > b.MaxSoC ||
+		b.SoC < 0 || b.SoC > 1 {
+		return ErrInvalidBattery
+	}
+	return nil
+}
+
+// Optimize computes the dispatch-plan minimizing the cost of grid-exchange
+// over the given steps using dynamic programming. The state of charge is
+// discretized into the given amount of levels. Energy left in the battery at
+// the end of the plan is valued at the average export-price.
+func Optimize(b Battery, steps []Step, stepSize time.Duration, levels int) Plan {
+	if levels < 2 {
+		levels = 2
+	}
+	hours := stepSize.Hours()
+	resolution := b.Capacity / float64(levels-1)
+	minLevel := int(math.Ceil(b.MinSoC*float64(levels-1) - 1e-9))
+	maxLevel := int(math.Floor(b.MaxSoC*float64(levels-1) + 1e-9))
+	start := int(math.Round(b.SoC * float64(levels-1)))
+	if start < minLevel {
+		minLevel = start
+	}
+	if start > maxLevel {
+		maxLevel = start
+	}
+
+	// the maximum change of level per step
+	maxUp := int(math.Floor(b.MaxCharge * b.ChargeEfficiency * hours / resolution))
+	maxDown := int(math.Floor(b.MaxDischarge / b.DischargeEfficiency * hours / resolution))
+
+	terminal := 0.0
+	for _, s := range steps {
+		terminal += s.ExportPrice
+	}
+	if len(steps) > 0 {
+		terminal /= float64(len(steps))
+	}
+
+	// value[k][l] is the minimum cost from step k on, if the battery is at
+	// level l at the beginning of step k
+	value := make([][]float64, len(steps)+1)
+	next := make([][]int, len(steps))
+	value[len(steps)] = make([]float64, levels)
+	for l := range value[len(steps)] {
+		value[len(steps)][l] = -float64(l) * resolution * b.DischargeEfficiency / 1000 * terminal
+	}
+
+	for k := len(steps) - 1; k >= 0; k-- {
+		value[k] = make([]float64, levels)
+		next[k] = make([]int, levels)
+		for l := minLevel; l <= maxLevel; l++ {
+			best := math.Inf(1)
+			for n := maxInt(minLevel, l-maxDown); n <= minInt(maxLevel, l+maxUp); n++ {
+				c := cost(b, steps[k], float64(n-l)*resolution, hours).Cost + value[k+1][n]
+				if c < best {
+					best = c
+					next[k][l] = n
+				}
+			}
+			value[k][l] = best
+		}
+	}
+
+	plan := Plan{
+		Actions: make([]Action, len(steps)),
+	}
+	l := start
+	for k, s := range steps {
+		n := next[k][l]
+		plan.Actions[k] = cost(b, s, float64(n-l)*resolution, hours)
+		plan.Actions[k].SoC = float64(n) / float64(levels-1)
+		plan.Cost += plan.Actions[k].Cost
+		l = n
+	}
+	return plan
+}
+
+// cost returns the action, that changes the stored energy by delta Wh during
+// the step.
+func cost(b Battery, s Step, delta, hours float64) Action {
+	battery := 0.0
+	if delta > 0 {
+		battery = delta / b.ChargeEfficiency / hours
+	} else if delta < 0 {
+		battery = delta * b.DischargeEfficiency / hours
+	}
+
+	net := s.Production - s.Load - battery
+	a := Action{
+		Time:    s.Time,
+		Battery: battery,
+	}
+	if net > s.ExportLimit {
+		a.GridExport = s.ExportLimit
+		a.Curtailed = net - s.ExportLimit
+	} else if net > 0 {
+		a.GridExport = net
+	} else {
+		a.GridImport = -net
+	}
+	a.Cost = (a.GridImport*s.ImportPrice - a.GridExport*s.ExportPrice) * hours / 1000
+	return a
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,87 @@
+package dispatch
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var battery = Battery{
+	Capacity:            10000,
+	MaxCharge:           5000,
+	MaxDischarge:        5000,
+	ChargeEfficiency:    1,
+	DischargeEfficiency: 1,
+	MinSoC:              0,
+	MaxSoC:              1,
+	SoC:                 0,
+}
+
+func TestOptimizeStoresSurplus(t *testing.T) {
+	start := time.Unix(0, 0)
+	steps := []Step{
+		{Time: start, Production: 5000, Load: 0, ImportPrice: 0.3, ExportPrice: 0.05, ExportLimit: math.Inf(1)},
+		{Time: start.Add(time.Hour), Production: 0, Load: 5000, ImportPrice: 0.3, ExportPrice: 0.05, ExportLimit: math.Inf(1)},
+	}
+	plan := Optimize(battery, steps, time.Hour, 101)
+
+	assert.InDelta(t, 5000, plan.Actions[0].Battery, 1e-6)
+	assert.InDelta(t, 0.5, plan.Actions[0].SoC, 1e-6)
+	assert.InDelta(t, -5000, plan.Actions[1].Battery, 1e-6)
+	assert.InDelta(t, 0, plan.Actions[1].GridImport, 1e-6)
+	assert.InDelta(t, 0, plan.Cost, 1e-6)
+}
+
+func TestOptimizeRespectsLimits(t *testing.T) {
+	start := time.Unix(0, 0)
+	b := battery
+	b.MaxCharge = 2000
+	steps := []Step{
+		{Time: start, Production: 5000, Load: 0, ImportPrice: 0.3, ExportPrice: 0.05, ExportLimit: math.Inf(1)},
+		{Time: start.Add(time.Hour), Production: 0, Load: 5000, ImportPrice: 0.3, ExportPrice: 0.05, ExportLimit: math.Inf(1)},
+	}
+	plan := Optimize(b, steps, time.Hour, 101)
+
+	assert.InDelta(t, 2000, plan.Actions[0].Battery, 1e-6)
+	assert.InDelta(t, 3000, plan.Actions[0].GridExport, 1e-6)
+	assert.InDelta(t, 3000, plan.Actions[1].GridImport, 1e-6)
+}
+
+func TestOptimizeArbitrage(t *testing.T) {
+	start := time.Unix(0, 0)
+	b := battery
+	b.SoC = 0.5
+	steps := []Step{
+		{Time: start, Production: 0, Load: 1000, ImportPrice: 0.1, ExportPrice: 0, ExportLimit: math.Inf(1)},
+		{Time: start.Add(time.Hour), Production: 0, Load: 1000, ImportPrice: 0.5, ExportPrice: 0, ExportLimit: math.Inf(1)},
+	}
+	plan := Optimize(b, steps, time.Hour, 101)
+
+	// discharge when energy is expensive
+	assert.True(t, plan.Actions[1].Battery <= -1000+1e-6)
+	assert.InDelta(t, 0, plan.Actions[1].GridImport, 1e-6)
+}
+
+func TestOptimizeAvoidsCurtailment(t *testing.T) {
+	start := time.Unix(0, 0)
+	b := battery
+	b.SoC = 0.5
+	steps := []Step{
+		{Time: start, Production: 5000, Load: 0, ImportPrice: 0.3, ExportPrice: 0.3, ExportLimit: 3000},
+		{Time: start.Add(time.Hour), Production: 0, Load: 0, ImportPrice: 0.3, ExportPrice: 0.3, ExportLimit: 3000},
+	}
+	plan := Optimize(b, steps, time.Hour, 101)
+
+	// the surplus beyond the export limit is stored
+	assert.InDelta(t, 0, plan.Actions[0].Curtailed, 1e-6)
+	assert.True(t, plan.Actions[0].Battery >= 2000-1e-6)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, battery.Validate())
+	b := battery
+	b.ChargeEfficiency = 0
+	assert.Equal(t, ErrInvalidBattery, b.Validate())
+}
@@ -0,0 +1,11 @@
+package optimization
+
+import (
+	"github.com/theMomax/openefs/optimization/dispatch"
+)
+
+// Run starts all optimization-subsystems. It must be called after the caches
+// were initialized.
+func Run() {
+	dispatch.Run()
+}
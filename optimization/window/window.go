@@ -0,0 +1,100 @@
+package window
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	timeutils "github.com/theMomax/openefs/utils/time"
+)
+
+// Error constants
+var (
+	ErrIllegalRequest = errors.New("the load's duration and energy must be positive and fit before the deadline")
+	ErrNoWindow       = errors.New("no window is covered by the production-forecast")
+)
+
+// Forecast returns the expected average production (in W) of the step at time
+// t and its uncertainty (e.g. the mean absolute error) in W. ok is false if no
+// forecast is available.
+type Forecast func(t time.Time) (expected, uncertainty float64, ok bool)
+
+// Load describes a flexible load, that runs at constant power.
+type Load struct {
+	Duration time.Duration
+	// Energy is the energy consumed during Duration in kWh.
+	Energy float64
+}
+
+// Power returns the load's average power in W.
+func (l Load) Power() float64 {
+	return l.Energy * 1000 / l.Duration.Hours()
+}
+
+// Window is a recommended time-window for running a Load.
+type Window struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Covered is the load's energy (in kWh), that is expected to be covered by
+	// production, if the forecast errs on the low side by its uncertainty.
+	Covered float64 `json:"covered"`
+	// Expected is the load's energy (in kWh), that is expected to be covered
+	// by production.
+	Expected float64 `json:"expected"`
+	// Coverage is the fraction (0 to 1) of the load's energy, that is Covered.
+	Coverage float64 `json:"coverage"`
+}
+
+// Best returns the window between from and until, in which the load is covered
+// best by the forecast production. Each step's production is reduced by its
+// uncertainty, so that reliable windows are preferred over uncertain ones. The
+// candidate windows start at from and at each step-boundary after it. If
+// several windows are equally good, the earliest one is returned.
+func Best(from, until time.Time, load Load, step time.Duration, forecast Forecast) (Window, error) {
+	if load.Duration <= 0 || load.Energy <= 0 || step <= 0 || from.Add(load.Duration).After(until) {
+		return Window{}, ErrIllegalRequest
+	}
+
+	var best *Window
+	for start := from; !start.Add(load.Duration).After(until); start = timeutils.Round(start, step).Add(step / 2) {
+		w, ok := evaluate(start, load, step, forecast)
+		if !ok {
+			continue
+		}
+		if best == nil || w.Covered > best.Covered+1e-9 {
+			best = &w
+		}
+	}
+
+	if best == nil {
+		return Window{}, ErrNoWindow
+	}
+	return *best, nil
+}
+
+// evaluate computes the window starting at start. ok is false if the forecast
+// does not cover the whole window.
+func evaluate(start time.Time, load Load, step time.Duration, forecast Forecast) (w Window, ok bool) {
+	w = Window{
+		From: start,
+		To:   start.Add(load.Duration),
+	}
+	power := load.Power()
+	for s := start; s.Before(w.To); {
+		t := timeutils.Round(s, step)
+		end := t.Add(step / 2)
+		if end.After(w.To) {
+			end = w.To
+		}
+		expected, uncertainty, ok := forecast(t)
+		if !ok {
+			return Window{}, false
+		}
+		hours := end.Sub(s).Hours()
+		w.Expected += math.Min(math.Max(expected, 0), power) / 1000 * hours
+		w.Covered += math.Min(math.Max(expected-uncertainty, 0), power) / 1000 * hours
+		s = end
+	}
+	w.Coverage = w.Covered / load.Energy
+	return w, true
+}
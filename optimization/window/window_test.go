@@ -0,0 +1,58 @@
+package window
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func forecast(values map[int64]float64, uncertainty float64) Forecast {
+	return func(t time.Time) (float64, float64, bool) {
+		v, ok := values[t.Unix()]
+		return v, uncertainty, ok
+	}
+}
+
+func TestBestPrefersSurplus(t *testing.T) {
+	f := forecast(map[int64]float64{
+		0:     0,
+		3600:  1000,
+		7200:  3000,
+		10800: 3000,
+		14400: 500,
+	}, 0)
+	w, err := Best(time.Unix(0, 0), time.Unix(14400, 0), Load{2 * time.Hour, 4}, time.Hour, f)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Unix(5400, 0), w.From)
+	assert.Equal(t, time.Unix(12600, 0), w.To)
+	assert.InDelta(t, 4, w.Covered, 1e-9)
+	assert.InDelta(t, 1, w.Coverage, 1e-9)
+}
+
+func TestBestConsidersUncertainty(t *testing.T) {
+	values := map[int64]float64{
+		0:    1500,
+		3600: 1400,
+	}
+	uncertain := func(t time.Time) (float64, float64, bool) {
+		v, ok := values[t.Unix()]
+		if t.Unix() == 0 {
+			return v, 800, ok
+		}
+		return v, 100, ok
+	}
+	w, err := Best(time.Unix(-1800, 0), time.Unix(5400, 0), Load{time.Hour, 1}, time.Hour, uncertain)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Unix(1800, 0), w.From)
+	assert.InDelta(t, 1, w.Expected, 1e-9)
+	assert.InDelta(t, 1, w.Covered, 1e-9)
+}
+
+func TestBestErrors(t *testing.T) {
+	f := forecast(map[int64]float64{0: 1000}, 0)
+	_, err := Best(time.Unix(0, 0), time.Unix(3600, 0), Load{2 * time.Hour, 1}, time.Hour, f)
+	assert.Equal(t, ErrIllegalRequest, err)
+	_, err = Best(time.Unix(3600, 0), time.Unix(10800, 0), Load{time.Hour, 1}, time.Hour, f)
+	assert.Equal(t, ErrNoWindow, err)
+}
@@ -0,0 +1,103 @@
+package persistence
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/theMomax/openefs/config"
+)
+
+// Config paths
+const (
+	PathDirectory = "persistence.directory"
+)
+
+func init() {
+	config.RootCtx.PersistentFlags().String(PathDirectory, "./state", "the directory the application's state is saved to on shutdown and restored from on startup (empty to disable)")
+	config.Viper.BindPFlag(PathDirectory, config.RootCtx.PersistentFlags().Lookup(PathDirectory))
+
+	config.OnInitialize(func() {
+		log = config.NewLogger()
+	})
+}
+
+var log *logrus.Logger
+
+type state struct {
+	save func() interface{}
+	load func([]byte) error
+}
+
+var states = make(map[string]state)
+var m = &sync.Mutex{}
+
+// Register registers a state to be persisted under the given name. save
+// returns a snapshot of the state, that can be encoded as JSON. load restores
+// the state from such an encoded snapshot.
+func Register(name string, save func() interface{}, load func([]byte) error) {
+	m.Lock()
+	defer m.Unlock()
+	states[name] = state{save, load}
+}
+
+// Load restores all registered states, that were saved before. States, that
+// cannot be restored, are skipped.
+func Load() {
+	dir := config.Viper.GetString(PathDirectory)
+	if dir == "" {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	for name, s := range states {
+		b, err := ioutil.ReadFile(file(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err == nil {
+			err = s.load(b)
+		}
+		if err != nil {
+			log.WithError(err).WithField("state", name).Warn("could not restore state")
+			continue
+		}
+		log.WithField("state", name).Debug("restored state")
+	}
+}
+
+// Save saves all registered states. Each state is written to a temporary file
+// first, so that a previously saved state is never left corrupt.
+func Save() error {
+	dir := config.Viper.GetString(PathDirectory)
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	m.Lock()
+	defer m.Unlock()
+	for name, s := range states {
+		b, err := json.Marshal(s.save())
+		if err != nil {
+			return err
+		}
+		tmp := file(dir, name) + ".tmp"
+		if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, file(dir, name)); err != nil {
+			return err
+		}
+		log.WithField("state", name).Debug("saved state")
+	}
+	return nil
+}
+
+func file(dir, name string) string {
+	return filepath.Join(dir, name+".json")
+}
@@ -0,0 +1,27 @@
+#!/usr/bin/python
+
+import tensorflow as tf
+import numpy as np
+import protocol
+import model_spec
+
+# Runs one fold of a walk-forward backtest (see models/production/backtest.go):
+# the freshly created model is trained on the samples, which all precede the
+# tests, and predicts the tests. The model is not saved. The predictions of all
+# tests are concatenated.
+
+request = protocol.read_request()
+model = tf.keras.models.load_model(request['model'])
+protocol.check_model(model, request)
+INPUT_SHAPE = model.input_shape[1:]
+OUTPUT_SHAPE = model.output_shape[-1]
+
+spec = protocol.spec(request)
+samples = protocol.samples(request, INPUT_SHAPE[0], OUTPUT_SHAPE)
+tests = protocol.samples(request, INPUT_SHAPE[0], OUTPUT_SHAPE, 'tests')
+
+model_spec.train(model, spec, samples, verbose=0)
+
+model_output = model.predict(np.asarray([t['inputs'] for t in tests]))
+
+protocol.respond(model_output.reshape(-1).tolist())
@@ -1,28 +1,26 @@
 #!/usr/bin/python
 
 import sys
-import tensorflow as tf
+import model_files
+import model_spec
 
+# The model's shape is defined by the schema stored alongside it (see
+# models/production/schema.go), its architecture by the spec (see
+# python/model_spec.py).
 
-INPUT_SHAPE = (2, 14)
-OUTPUT_SHAPE = 1
-
-if len(sys.argv) != 2:
-    print('Illegal number of arguments: expected <OutputPath>')
+if len(sys.argv) != 6:
+    print('Illegal number of arguments: expected <OutputPath> <InputSteps> <Features> <OutputSteps> <Spec>')
     exit(1)
 
+INPUT_SHAPE = (int(sys.argv[2]), int(sys.argv[3]))
+OUTPUT_SHAPE = int(sys.argv[4])
+SPEC = model_spec.parse(sys.argv[5])
 
-model = tf.keras.models.Sequential()
-model.add(tf.keras.layers.Dense(32,input_shape=INPUT_SHAPE, activation='relu'))
-model.add(tf.keras.layers.LSTM(32, return_sequences=False, stateful=False))
-model.add(tf.keras.layers.Dense(32, activation='relu'))
-model.add(tf.keras.layers.Dense(32, activation='relu'))
-model.add(tf.keras.layers.Dense(OUTPUT_SHAPE, activation='relu'))
 
-model.compile(optimizer=tf.keras.optimizers.RMSprop(), loss='mae')
+model = model_spec.build(SPEC, INPUT_SHAPE, OUTPUT_SHAPE)
 
 model.summary()
 
-model.save(sys.argv[1])
+model_files.save(model, sys.argv[1])
 
-print('Saved production-model to ' + sys.argv[1] + ' !')
\ No newline at end of file
+print('Saved production-model to ' + sys.argv[1] + ' !')
@@ -0,0 +1,23 @@
+#!/usr/bin/python
+
+import tensorflow as tf
+import numpy as np
+import protocol
+
+# The request's inputs hold INPUT_SHAPE[0] steps: the preceding steps followed
+# by the steps to be predicted, whose production is set to zero. The model
+# predicts OUTPUT_SHAPE steps at once.
+
+request = protocol.read_request()
+model = tf.keras.models.load_model(request['model'])
+INPUT_SHAPE = model.input_shape[1:]
+OUTPUT_SHAPE = model.output_shape[-1]
+protocol.check_model(model, request, outputs=protocol.outputs(request))
+
+model_input = np.asarray([protocol.inputs(request, INPUT_SHAPE[0])])
+print('Model input:')
+print(model_input)
+
+model_output = model.predict(model_input)[0].tolist()
+
+protocol.respond(model_output)
@@ -0,0 +1,40 @@
+#!/usr/bin/python
+
+import os
+import shutil
+import tensorflow as tf
+from protocol import schema_path
+
+
+def temp_path(path):
+    base, ext = os.path.splitext(path)
+    return base + '.tmp' + ext
+
+
+def snapshot_path(path):
+    base, ext = os.path.splitext(path)
+    return base + '.last' + ext
+
+
+def copy(src, dst):
+    shutil.copyfile(src, temp_path(dst))
+    os.replace(temp_path(dst), dst)
+
+
+def save(model, path):
+    # The model is written to a temporary file, which replaces the actual file
+    # only once it is complete and can be loaded, so that a crash never leaves
+    # a corrupt model behind. The replaced model is kept as snapshot of the
+    # last good model together with its schema, so that the snapshot is only
+    # restored, if it matches the configuration.
+    tmp = temp_path(path)
+    model.save(tmp)
+    tf.keras.models.load_model(tmp)
+    if os.path.exists(path):
+        snapshot = snapshot_path(path)
+        copy(path, snapshot)
+        if os.path.exists(schema_path(path)):
+            copy(schema_path(path), schema_path(snapshot))
+        elif os.path.exists(schema_path(snapshot)):
+            os.remove(schema_path(snapshot))
+    os.replace(tmp, path)
@@ -0,0 +1,111 @@
+#!/usr/bin/python
+
+# Builds, compiles and trains the production-network as described by the spec
+# passed from openefs (see models/production/spec.go).
+#
+# Spec: {"layers": ["dense" | "lstm" | "gru", ...], "units": <units>,
+#        "optimizer": "rmsprop" | "adam" | "sgd", "learningRate": <rate>,
+#        "loss": "mae" | "mse" | "huber", "epochs": <epochs>}
+
+# Tensorflow is only imported when required, so that specs can be parsed
+# without it.
+
+import json
+
+RECURRENT = ('lstm', 'gru')
+
+OPTIMIZERS = ('rmsprop', 'adam', 'sgd')
+
+LOSSES = ('mae', 'mse', 'huber')
+
+
+def parse(text):
+    spec = json.loads(text)
+    for layer in spec['layers']:
+        if layer != 'dense' and layer not in RECURRENT:
+            raise ValueError('unknown layer ' + str(layer))
+    if spec['optimizer'] not in OPTIMIZERS:
+        raise ValueError('unknown optimizer ' + str(spec['optimizer']))
+    if spec['loss'] not in LOSSES:
+        raise ValueError('unknown loss ' + str(spec['loss']))
+    return spec
+
+
+def build(spec, input_shape, outputs):
+    import tensorflow as tf
+    layers = spec['layers']
+    units = spec['units']
+    model = tf.keras.models.Sequential()
+    model.add(tf.keras.layers.InputLayer(input_shape=input_shape))
+    sequence = True
+    for i, layer in enumerate(layers):
+        if layer in RECURRENT:
+            # only the last recurrent layer reduces the sequence of steps
+            more = any(l in RECURRENT for l in layers[i+1:])
+            cls = tf.keras.layers.LSTM if layer == 'lstm' else tf.keras.layers.GRU
+            model.add(cls(units, return_sequences=more, stateful=False))
+            sequence = more
+        else:
+            model.add(tf.keras.layers.Dense(units, activation='relu'))
+    if sequence:
+        model.add(tf.keras.layers.Flatten())
+    model.add(tf.keras.layers.Dense(outputs, activation='relu'))
+    compile_model(model, spec)
+    return model
+
+
+def compile_model(model, spec):
+    import tensorflow as tf
+    optimizers = {
+        'rmsprop': tf.keras.optimizers.RMSprop,
+        'adam': tf.keras.optimizers.Adam,
+        'sgd': tf.keras.optimizers.SGD,
+    }
+    losses = {
+        'mae': 'mae',
+        'mse': 'mse',
+        'huber': tf.keras.losses.Huber(),
+    }
+    model.compile(optimizer=optimizers[spec['optimizer']](spec['learningRate']), loss=losses[spec['loss']])
+
+
+def apply(model, spec):
+    import tensorflow as tf
+    # The optimizer's state is kept, unless the optimizer or loss changed.
+    optimizer = type(model.optimizer).__name__.lower()
+    loss = model.loss if isinstance(model.loss, str) else type(model.loss).__name__.lower()
+    if optimizer != spec['optimizer'] or loss != spec['loss']:
+        compile_model(model, spec)
+    else:
+        tf.keras.backend.set_value(model.optimizer.lr, spec['learningRate'])
+
+
+def train(model, spec, samples, verbose=1):
+    # Fits the model to the samples as described by the spec. This is shared
+    # by training and backtests, so that backtests evaluate the model as it is
+    # trained in production. Censored targets are only lower bounds of the
+    # actual production, thus they are raised to the model's prediction before
+    # each epoch, so that the model is not penalized for predicting more than
+    # them.
+    import numpy as np
+    model_input = np.asarray([s['inputs'] for s in samples])
+    model_target = np.asarray([s['targets'] for s in samples])
+    censored = np.asarray([s['censored'] for s in samples])
+    if verbose:
+        print('Model input:')
+        print(model_input)
+        print('Model target:')
+        print(model_target)
+
+    apply(model, spec)
+    for epoch in range(spec['epochs']):
+        target = model_target
+        if censored.any():
+            prediction = model.predict(model_input).reshape(model_target.shape)
+            target = np.where(censored, np.maximum(model_target, prediction), model_target)
+        model.fit(model_input, target,
+            epochs=1,
+            steps_per_epoch=1,
+            shuffle=False,
+            verbose=verbose,
+        )
@@ -0,0 +1,142 @@
+#!/usr/bin/python
+
+# Implements version 1 of the protocol used by openefs for running the
+# production-model (see models/production/protocol.go). A single JSON request
+# is read from stdin and a single JSON response is written to stdout. All other
+# output is redirected to stderr.
+#
+# The spec is only sent for training and backtests (see python/model_spec.py).
+# The tests are only sent for backtests.
+#
+# The request's features must match the schema stored alongside the model.
+#
+# Request:  {"version": 1, "model": <path>, "features": [<name>, ...],
+#            "inputs": [[<feature>, ...], ...], "outputs": <steps>,
+#            "samples": [{"inputs": [[<feature>, ...], ...],
+#                         "targets": [<production>, ...],
+#                         "censored": [<bool>, ...]}, ...],
+#            "tests": [<sample>, ...], "spec": <spec>}
+# Response: {"version": 1, "output": [<production>, ...]} or
+#           {"version": 1, "error": <message>}
+
+import json
+import math
+import model_spec
+import os
+import sys
+
+VERSION = 1
+
+_stdout = sys.stdout
+sys.stdout = sys.stderr
+
+
+def respond(output=None):
+    response = {'version': VERSION}
+    if output is not None:
+        response['output'] = [float(o) for o in output]
+    json.dump(response, _stdout)
+    _stdout.flush()
+
+
+def fail(message):
+    json.dump({'version': VERSION, 'error': message}, _stdout)
+    _stdout.flush()
+    exit(1)
+
+
+def read_request():
+    try:
+        request = json.load(sys.stdin)
+    except ValueError as e:
+        fail('request is not valid JSON: ' + str(e))
+    if not isinstance(request, dict):
+        fail('request must be an object')
+    if request.get('version') != VERSION:
+        fail('unsupported protocol-version ' + str(request.get('version')) + ', expected ' + str(VERSION))
+    if not isinstance(request.get('model'), str):
+        fail('model must be a path')
+    # the features must be the ones the model was built for
+    try:
+        with open(schema_path(request['model'])) as f:
+            schema = json.load(f)
+    except (OSError, ValueError) as e:
+        fail('could not read schema of model: ' + str(e))
+    expected = [feature['name'] for feature in schema['features']]
+    if request.get('features') != expected:
+        fail('unexpected features ' + str(request.get('features')) + ', expected ' + str(expected))
+    return request
+
+
+def schema_path(path):
+    # The naming matches the one of schemaPath in models/production/schema.go.
+    base, _ = os.path.splitext(path)
+    return base + '.schema.json'
+
+
+def features(request):
+    return request['features']
+
+
+def check_model(model, request, steps=None, outputs=None):
+    # the model's input must consist of steps rows of all features
+    if model.input_shape[-1] != len(features(request)):
+        fail('model expects ' + str(model.input_shape[-1]) + ' features per step, got ' + str(len(features(request))))
+    if steps is not None and model.input_shape[1] != steps:
+        fail('model expects ' + str(model.input_shape[1]) + ' steps, got ' + str(steps))
+    if outputs is not None and model.output_shape[-1] != outputs:
+        fail('model predicts ' + str(model.output_shape[-1]) + ' steps, got ' + str(outputs))
+
+
+def check_rows(request, rows, count, name):
+    if not isinstance(rows, list) or len(rows) != count:
+        fail(name + ' must be a list of ' + str(count) + ' steps')
+    for i, row in enumerate(rows):
+        check_values(row, len(features(request)), name + '[' + str(i) + ']')
+
+
+def check_values(values, count, name):
+    if not isinstance(values, list) or len(values) != count:
+        fail(name + ' must be a list of ' + str(count) + ' values')
+    for v in values:
+        if isinstance(v, bool) or not isinstance(v, (int, float)) or not math.isfinite(v):
+            fail(name + ' must only contain finite numbers')
+
+
+def inputs(request, count):
+    rows = request.get('inputs')
+    check_rows(request, rows, count, 'inputs')
+    return rows
+
+
+def outputs(request):
+    n = request.get('outputs')
+    if isinstance(n, bool) or not isinstance(n, int) or n < 1:
+        fail('outputs must be a positive integer')
+    return n
+
+
+def samples(request, steps, outputs, key='samples'):
+    samples = request.get(key)
+    if not isinstance(samples, list) or len(samples) == 0:
+        fail(key + ' must be a non-empty list')
+    for i, s in enumerate(samples):
+        name = key + '[' + str(i) + ']'
+        if not isinstance(s, dict):
+            fail(name + ' must be an object')
+        check_rows(request, s.get('inputs'), steps, name + '.inputs')
+        check_values(s.get('targets'), outputs, name + '.targets')
+        censored = s.get('censored')
+        if not isinstance(censored, list) or len(censored) != outputs or not all(isinstance(c, bool) for c in censored):
+            fail(name + '.censored must be a list of ' + str(outputs) + ' booleans')
+    return samples
+
+
+def spec(request):
+    s = request.get('spec')
+    if not isinstance(s, dict):
+        fail('spec must be an object')
+    try:
+        return model_spec.parse(json.dumps(s))
+    except (KeyError, TypeError, ValueError) as e:
+        fail('invalid spec: ' + str(e))
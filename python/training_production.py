@@ -1,54 +1,25 @@
 #!/usr/bin/python
 
-import sys
 import tensorflow as tf
-import numpy as np
-import tensorflow.keras.backend as K
+import model_files
+import protocol
+import model_spec
 
-INPUT_SHAPE = (2,14)
-OUTPUT_SHAPE = 1
+# Each sample consists of the INPUT_SHAPE[0] steps preceding the target, the
+# target value and a flag, which marks the target as censored, i.e. clipped by
+# curtailment.
 
-if (len(sys.argv) - 2) % (INPUT_SHAPE[0] * INPUT_SHAPE[1] + OUTPUT_SHAPE) != 0 :
-    print('Illegal number of arguments: expected <ModelPath> <InputValues>... (Number must be a multiple of INPUT_SHAPE[0] * INPUT_SHAPE[1] + OUTPUT_SHAPE: ' + str(INPUT_SHAPE[0] * INPUT_SHAPE[1] + OUTPUT_SHAPE) + ')' + ' got ' + str(len(sys.argv)-2))
-    exit(1)
+request = protocol.read_request()
+model = tf.keras.models.load_model(request['model'])
+protocol.check_model(model, request)
+INPUT_SHAPE = model.input_shape[1:]
+OUTPUT_SHAPE = model.output_shape[-1]
 
-batch_input = []
-batch_target = []
+samples = protocol.samples(request, INPUT_SHAPE[0], OUTPUT_SHAPE)
 
-i = 2
-while (i <= len(sys.argv) - (INPUT_SHAPE[0] * INPUT_SHAPE[1]) + OUTPUT_SHAPE):
-    timesteps = []
-    j = 0
-    while (j < INPUT_SHAPE[1] * INPUT_SHAPE[0]):
-        features = []
-        for k in range(0,INPUT_SHAPE[1]):
-            features.append(float(sys.argv[i+j+k]))
+model_spec.train(model, protocol.spec(request), samples)
 
-        j += INPUT_SHAPE[1]
-        timesteps.append(features)
+model_files.save(model, request['model'])
 
-    i += (INPUT_SHAPE[0] * INPUT_SHAPE[1] + OUTPUT_SHAPE)
-    batch_input.append(timesteps)
-    batch_target.append(float(sys.argv[i-1]))
-
-
-
-model_input = np.asarray(batch_input)
-print('Model input:')
-print(model_input)
-
-model_target = np.asarray(batch_target)
-print('Model target:')
-print(model_target)
-
-model = tf.keras.models.load_model(sys.argv[1])
-K.set_value(model.optimizer.lr, 0.001)
-model.fit(model_input, model_target, 
-    epochs=40,
-    steps_per_epoch=1,
-    shuffle=False,
-)
-
-model.save(sys.argv[1])
-
-print('Saved production-model to ' + sys.argv[1] + ' !')
\ No newline at end of file
+print('Saved production-model to ' + request['model'] + ' !')
+protocol.respond()
@@ -0,0 +1,25 @@
+#!/usr/bin/python
+
+import tensorflow as tf
+import model_files
+import protocol
+import model_spec
+
+# Each sample consists of INPUT_SHAPE[0] steps (see inference_production_direct.py),
+# OUTPUT_SHAPE target values and OUTPUT_SHAPE flags, which mark the targets as
+# censored, i.e. clipped by curtailment.
+
+request = protocol.read_request()
+model = tf.keras.models.load_model(request['model'])
+protocol.check_model(model, request)
+INPUT_SHAPE = model.input_shape[1:]
+OUTPUT_SHAPE = model.output_shape[-1]
+
+samples = protocol.samples(request, INPUT_SHAPE[0], OUTPUT_SHAPE)
+
+model_spec.train(model, protocol.spec(request), samples)
+
+model_files.save(model, request['model'])
+
+print('Saved production-model to ' + request['model'] + ' !')
+protocol.respond()
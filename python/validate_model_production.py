@@ -0,0 +1,32 @@
+#!/usr/bin/python
+
+import sys
+import tensorflow as tf
+import numpy as np
+
+# Exits with code 2, if the model does not have the expected shape, and with
+# code 1, if it is invalid otherwise.
+
+if len(sys.argv) != 5:
+    print('Illegal number of arguments: expected <ModelPath> <InputSteps> <Features> <OutputSteps>')
+    exit(1)
+
+INPUT_SHAPE = (int(sys.argv[2]), int(sys.argv[3]))
+OUTPUT_SHAPE = int(sys.argv[4])
+
+model = tf.keras.models.load_model(sys.argv[1])
+
+if tuple(model.input_shape[1:]) != INPUT_SHAPE or model.output_shape[-1] != OUTPUT_SHAPE:
+    print('Model has input-shape ' + str(model.input_shape[1:]) + ' and output-shape ' + str(model.output_shape[-1]) + ', expected ' + str(INPUT_SHAPE) + ' and ' + str(OUTPUT_SHAPE))
+    exit(2)
+
+# dry inference on a single sample of zeros
+model_input = np.zeros((1,) + INPUT_SHAPE)
+model_output = model.predict(model_input)
+
+if not np.all(np.isfinite(model_output)):
+    print('Model output is not finite:')
+    print(model_output)
+    exit(1)
+
+print('Validated production-model ' + sys.argv[1] + ' !')
@@ -1,6 +1,10 @@
 package server
 
 import (
+	"context"
+	"net/http"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/theMomax/openefs/config"
 	"github.com/theMomax/openefs/handlers"
@@ -10,6 +14,9 @@ import (
 const (
 	PathIP   = "server.ip"
 	PathPort = "server.port"
+	// PathShutdownTimeout is the maximum duration to wait for running requests
+	// on shutdown.
+	PathShutdownTimeout = "server.shutdowntimeout"
 )
 
 func init() {
@@ -18,10 +25,16 @@ func init() {
 
 	config.RootCtx.PersistentFlags().UintP(PathPort, "p", 8080, "server port")
 	config.Viper.BindPFlag(PathPort, config.RootCtx.PersistentFlags().Lookup(PathPort))
+
+	config.RootCtx.PersistentFlags().Duration(PathShutdownTimeout, 10*time.Second, "the maximum duration to wait for running requests on shutdown")
+	config.Viper.BindPFlag(PathShutdownTimeout, config.RootCtx.PersistentFlags().Lookup(PathShutdownTimeout))
 }
 
-// Run starts the REST api server.
-func Run() error {
+// Run starts the REST api server. Once ctx is done, the server stops accepting
+// connections and waits for running requests to complete, before it returns
+// nil. Requests, that are still running after the configured timeout, are
+// aborted.
+func Run(ctx context.Context) error {
 	switch config.Env() {
 	case config.Development:
 		gin.SetMode(gin.DebugMode)
@@ -37,5 +50,26 @@ func Run() error {
 
 	handlers.Register(&r.RouterGroup)
 
-	return r.Run(config.Viper.GetString(PathIP) + ":" + config.Viper.GetString(PathPort))
+	srv := &http.Server{
+		Addr:    config.Viper.GetString(PathIP) + ":" + config.Viper.GetString(PathPort),
+		Handler: r,
+	}
+
+	shutdown := make(chan error)
+	go func() {
+		<-ctx.Done()
+		timeout, cancel := context.WithTimeout(context.Background(), config.Viper.GetDuration(PathShutdownTimeout))
+		defer cancel()
+		err := srv.Shutdown(timeout)
+		if err != nil {
+			// e.g. subscriptions, that never complete by themselves
+			err = srv.Close()
+		}
+		shutdown <- err
+	}()
+
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return <-shutdown
 }
@@ -0,0 +1,140 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+// States of a Breaker
+const (
+	// Closed is the state of a Breaker, that allows all calls.
+	Closed = "closed"
+	// Open is the state of a Breaker, that rejects all calls until its
+	// cooldown has passed.
+	Open = "open"
+	// HalfOpen is the state of a Breaker, that allows a single trial-call
+	// after its cooldown has passed. The Breaker closes, if the trial
+	// succeeds, and opens again otherwise.
+	HalfOpen = "half-open"
+)
+
+// Status describes a Breaker's current state.
+type Status struct {
+	State string `json:"state"`
+	// Failures is the amount of consecutive failures.
+	Failures uint `json:"failures"`
+	// Opened is the time the Breaker opened at. It is nil, if the Breaker is
+	// closed.
+	Opened *time.Time `json:"opened,omitempty"`
+	// LastError is the error of the latest failure.
+	LastError string `json:"lastError,omitempty"`
+}
+
+// Breaker is a circuit breaker. It opens after a given amount of consecutive
+// failures and rejects all calls until a cooldown has passed. Breaker is safe
+// for concurrent use.
+type Breaker struct {
+	threshold uint
+	cooldown  time.Duration
+
+	m        sync.Mutex
+	state    string
+	failures uint
+	opened   time.Time
+	trial    bool
+	err      error
+}
+
+// New returns a closed Breaker, that opens after threshold consecutive
+// failures and stays open for the given cooldown.
+func New(threshold uint, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     Closed,
+	}
+}
+
+// Allow returns true, if a call may be made at time now. The caller must
+// report the call's outcome via Success, Failure or Release.
+func (b *Breaker) Allow(now time.Time) bool {
+	b.m.Lock()
+	defer b.m.Unlock()
+	switch b.state {
+	case Open:
+		if now.Sub(b.opened) < b.cooldown {
+			return false
+		}
+		b.state = HalfOpen
+		b.trial = true
+		return true
+	case HalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	}
+	return true
+}
+
+// Success reports a successful call. It closes the Breaker.
+func (b *Breaker) Success() {
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.state = Closed
+	b.failures = 0
+	b.opened = time.Time{}
+	b.trial = false
+}
+
+// Release reports a call, whose outcome does not tell whether the called
+// service is available, e.g. because it was canceled. The Breaker's state is
+// kept, but another trial-call is allowed, if it is half-open.
+func (b *Breaker) Release() {
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.trial = false
+}
+
+// Failure reports a failed call at time now. It returns true, if the Breaker
+// opened due to this failure.
+func (b *Breaker) Failure(now time.Time, err error) (opened bool) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.failures++
+	b.err = err
+	b.trial = false
+	if b.state == HalfOpen || (b.state == Closed && b.failures >= b.threshold) {
+		b.state = Open
+		b.opened = now
+		return true
+	}
+	return false
+}
+
+// Reset closes the Breaker and forgets the latest failure.
+func (b *Breaker) Reset() {
+	b.Success()
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.err = nil
+}
+
+// Status returns the Breaker's current state.
+func (b *Breaker) Status() Status {
+	b.m.Lock()
+	defer b.m.Unlock()
+	s := Status{
+		State:    b.state,
+		Failures: b.failures,
+	}
+	if b.state != Closed {
+		opened := b.opened
+		s.Opened = &opened
+	}
+	if b.err != nil {
+		s.LastError = b.err.Error()
+	}
+	return s
+}
@@ -0,0 +1,86 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errTest = errors.New("test")
+
+func TestOpensAfterThreshold(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := New(3, time.Minute)
+
+	assert.False(t, b.Failure(now, errTest))
+	assert.False(t, b.Failure(now, errTest))
+	assert.True(t, b.Allow(now))
+	assert.True(t, b.Failure(now, errTest))
+	assert.False(t, b.Allow(now))
+	assert.Equal(t, Open, b.Status().State)
+	assert.Equal(t, uint(3), b.Status().Failures)
+	assert.Equal(t, errTest.Error(), b.Status().LastError)
+}
+
+func TestSuccessResetsFailures(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := New(2, time.Minute)
+
+	b.Failure(now, errTest)
+	b.Success()
+	assert.False(t, b.Failure(now, errTest))
+	assert.Equal(t, Closed, b.Status().State)
+}
+
+func TestHalfOpenAllowsSingleTrial(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := New(1, time.Minute)
+	b.Failure(now, errTest)
+
+	assert.False(t, b.Allow(now.Add(59*time.Second)))
+	assert.True(t, b.Allow(now.Add(time.Minute)))
+	assert.Equal(t, HalfOpen, b.Status().State)
+	assert.False(t, b.Allow(now.Add(time.Minute)))
+
+	// a failed trial opens the breaker for another cooldown
+	assert.True(t, b.Failure(now.Add(2*time.Minute), errTest))
+	assert.False(t, b.Allow(now.Add(2*time.Minute)))
+	assert.True(t, b.Allow(now.Add(3*time.Minute)))
+
+	b.Success()
+	assert.Equal(t, Closed, b.Status().State)
+	assert.True(t, b.Allow(now.Add(3*time.Minute)))
+	assert.True(t, b.Allow(now.Add(3*time.Minute)))
+}
+
+func TestReleaseAllowsAnotherTrial(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := New(1, time.Minute)
+	b.Failure(now, errTest)
+
+	assert.True(t, b.Allow(now.Add(time.Minute)))
+	assert.False(t, b.Allow(now.Add(time.Minute)))
+
+	// e.g. the trial was canceled, thus the breaker stays half-open
+	b.Release()
+	assert.Equal(t, HalfOpen, b.Status().State)
+	assert.True(t, b.Allow(now.Add(time.Minute)))
+	assert.False(t, b.Allow(now.Add(time.Minute)))
+
+	b.Success()
+	assert.Equal(t, Closed, b.Status().State)
+}
+
+func TestReleaseKeepsClosedBreakerClosed(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := New(2, time.Minute)
+	b.Failure(now, errTest)
+
+	assert.True(t, b.Allow(now))
+	b.Release()
+	assert.Equal(t, Closed, b.Status().State)
+	assert.Equal(t, uint(1), b.Status().Failures)
+	assert.True(t, b.Failure(now, errTest))
+}
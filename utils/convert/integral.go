@@ -3,6 +3,8 @@ package convert
 import (
 	"errors"
 	"time"
+
+	timeutils "github.com/theMomax/openefs/utils/time"
 )
 
 // Accuracy of the integration-steps
@@ -29,7 +31,7 @@ func Integrate(from time.Time, to time.Time, power func(at time.Time) *float64,
 
 	sum := 0.0
 	count := 0
-	for i := from; i.Sub(to) <= 0; i.Add(a) {
+	for i := from; i.Sub(to) <= 0; i = i.Add(a) {
 		p := power(i)
 		if p == nil {
 			return 0.0, ErrNoData
@@ -44,3 +46,59 @@ func Integrate(from time.Time, to time.Time, power func(at time.Time) *float64,
 
 	return (avg / 1000) * dur.Hours(), nil
 }
+
+// Energy is the result of integrating step-wise power over some period.
+type Energy struct {
+	// KWh is the energy produced during the parts of the period, for which
+	// power was available.
+	KWh float64 `json:"kWh"`
+	// Coverage is the fraction (0 to 1) of the period, for which power was
+	// available.
+	Coverage float64 `json:"coverage"`
+}
+
+// Extrapolated returns the energy expected for the whole period, assuming the
+// average power of the covered parts applies to the uncovered parts as well.
+func (e Energy) Extrapolated() float64 {
+	if e.Coverage == 0 {
+		return 0
+	}
+	return e.KWh / e.Coverage
+}
+
+// IntegrateSteps integrates step-wise average power from one point in time to
+// another. A step is identified by its time t and covers all points in time,
+// that are rounded to t, i.e. [t-step/2, t+step/2). The power-function is
+// called with each step's time and returns the step's average power in Watts
+// or nil, if it is unknown. Steps, that are only partially within the period,
+// are weighted by their overlap. The result's unit is kWh. ErrNoData is
+// returned if no power is known for the whole period.
+func IntegrateSteps(from time.Time, to time.Time, step time.Duration, power func(step time.Time) *float64) (Energy, error) {
+	if from.After(to) || step <= 0 {
+		return Energy{}, ErrIllegalTimestamps
+	}
+	if from.Equal(to) {
+		return Energy{Coverage: 1}, nil
+	}
+
+	e := Energy{}
+	var covered time.Duration
+	for start := from; start.Before(to); {
+		s := timeutils.Round(start, step)
+		end := s.Add(step / 2)
+		if end.After(to) {
+			end = to
+		}
+		if p := power(s); p != nil {
+			e.KWh += (*p / 1000) * end.Sub(start).Hours()
+			covered += end.Sub(start)
+		}
+		start = end
+	}
+
+	if covered == 0 {
+		return Energy{}, ErrNoData
+	}
+	e.Coverage = float64(covered) / float64(to.Sub(from))
+	return e, nil
+}
@@ -0,0 +1,64 @@
+package convert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func constant(p float64) func(time.Time) *float64 {
+	return func(time.Time) *float64 {
+		return &p
+	}
+}
+
+func TestIntegrate(t *testing.T) {
+	from := time.Unix(0, 0)
+	e, err := Integrate(from, from.Add(2*time.Hour), constant(1000))
+	assert.NoError(t, err)
+	assert.InDelta(t, 2, e, 1e-9)
+}
+
+func TestIntegrateSteps(t *testing.T) {
+	from := time.Unix(0, 0)
+	e, err := IntegrateSteps(from, from.Add(2*time.Hour), time.Hour, constant(1000))
+	assert.NoError(t, err)
+	assert.InDelta(t, 2, e.KWh, 1e-9)
+	assert.InDelta(t, 1, e.Coverage, 1e-9)
+}
+
+func TestIntegrateStepsPartial(t *testing.T) {
+	from := time.Unix(0, 0)
+	// the step at 0:00 covers [-0:30, 0:30), the one at 1:00 [0:30, 1:30)
+	power := func(s time.Time) *float64 {
+		p := float64(s.Sub(from)/time.Hour+1) * 1000
+		return &p
+	}
+	e, err := IntegrateSteps(from.Add(15*time.Minute), from.Add(45*time.Minute), time.Hour, power)
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.25*1+0.25*2, e.KWh, 1e-9)
+	assert.InDelta(t, 1, e.Coverage, 1e-9)
+}
+
+func TestIntegrateStepsMissing(t *testing.T) {
+	from := time.Unix(0, 0)
+	power := func(s time.Time) *float64 {
+		if s.Equal(from.Add(time.Hour)) {
+			return nil
+		}
+		p := 1000.0
+		return &p
+	}
+	e, err := IntegrateSteps(from, from.Add(2*time.Hour), time.Hour, power)
+	assert.NoError(t, err)
+	assert.InDelta(t, 1, e.KWh, 1e-9)
+	assert.InDelta(t, 0.5, e.Coverage, 1e-9)
+	assert.InDelta(t, 2, e.Extrapolated(), 1e-9)
+
+	_, err = IntegrateSteps(from, from.Add(time.Hour), time.Hour, func(time.Time) *float64 { return nil })
+	assert.Equal(t, ErrNoData, err)
+
+	_, err = IntegrateSteps(from.Add(time.Hour), from, time.Hour, constant(1))
+	assert.Equal(t, ErrIllegalTimestamps, err)
+}
@@ -0,0 +1,55 @@
+package metadata
+
+// Confidence describes how reliable the data associated with some Metadata
+// is.
+type Confidence uint8
+
+// Confidence levels
+const (
+	// High confidence is assigned to all data, that was obtained the regular
+	// way.
+	High Confidence = iota
+	// Low confidence is assigned to data, that was obtained using some
+	// fallback-mechanism, e.g. because required inputs were missing.
+	Low
+)
+
+func (c Confidence) String() string {
+	switch c {
+	case High:
+		return "high"
+	case Low:
+		return "low"
+	default:
+		return "unknown"
+	}
+}
+
+// Qualified is Metadata, that states the confidence in its associated data.
+type Qualified interface {
+	Metadata
+	Confidence() Confidence
+}
+
+// Annotated adds qualitative information to some Metadata.
+type Annotated struct {
+	Metadata
+	// Quality is the confidence in the associated data.
+	Quality Confidence
+	// Reason describes why the Quality was assigned.
+	Reason string
+}
+
+// Confidence returns the confidence in the associated data.
+func (a *Annotated) Confidence() Confidence {
+	return a.Quality
+}
+
+// ConfidenceOf returns the confidence stated by m. Metadata, that does not
+// state a confidence, is of High confidence.
+func ConfidenceOf(m Metadata) Confidence {
+	if q, ok := m.(Qualified); ok {
+		return q.Confidence()
+	}
+	return High
+}
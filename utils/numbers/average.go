@@ -55,6 +55,27 @@ func (a *Average) Apply(args ...float64) {
 	a.count++
 }
 
+// State is the serializable state of an Average. It does not contain the
+// Average's weight and operator.
+type State struct {
+	Sum   float64 `json:"sum"`
+	Count float64 `json:"count"`
+}
+
+// State returns the Average's current state.
+func (a *Average) State() State {
+	return State{
+		Sum:   a.sum,
+		Count: a.count,
+	}
+}
+
+// Restore replaces the Average's state with s.
+func (a *Average) Restore(s State) {
+	a.sum = s.Sum
+	a.count = s.Count
+}
+
 func (a *Average) Get() float64 {
 	if a.count == 0.0 {
 		return 0.0
@@ -45,3 +45,17 @@ func TestABSDIFFNegative(t *testing.T) {
 	assert.GreaterOrEqual(t, ABSDIFF(31805.82, 46888.98), 0.0)
 	assert.GreaterOrEqual(t, ABSDIFF(28303.42, 29310.19), 0.0)
 }
+
+func TestRestore(t *testing.T) {
+	a := NewAverageSum(10)
+	a.Apply(1)
+	a.Apply(3)
+
+	b := NewAverageSum(10)
+	b.Restore(a.State())
+	assert.Equal(t, a.Get(), b.Get())
+
+	a.Apply(5)
+	b.Apply(5)
+	assert.Equal(t, a.Get(), b.Get())
+}
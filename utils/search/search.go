@@ -0,0 +1,62 @@
+// Package search generates the candidates of a hyperparameter-search.
+package search
+
+import "math/rand"
+
+// Dimension is a parameter and the values it is searched over.
+type Dimension struct {
+	Name   string
+	Values []string
+}
+
+// Candidate assigns a value to each Dimension of a search-space.
+type Candidate map[string]string
+
+// Size returns the amount of distinct candidates in space.
+func Size(space []Dimension) int {
+	size := 1
+	for _, d := range space {
+		size *= len(d.Values)
+	}
+	return size
+}
+
+// Grid returns all candidates in space. The last Dimension varies fastest.
+func Grid(space []Dimension) []Candidate {
+	candidates := make([]Candidate, 0, Size(space))
+	for i := 0; i < Size(space); i++ {
+		candidates = append(candidates, at(space, i))
+	}
+	return candidates
+}
+
+// Random returns n distinct candidates drawn uniformly from space using r. All
+// candidates are returned in random order, if there are at most n.
+func Random(space []Dimension, n int, r *rand.Rand) []Candidate {
+	size := Size(space)
+	if n > size {
+		n = size
+	}
+	candidates := make([]Candidate, 0, n)
+	drawn := make(map[int]bool, n)
+	for len(candidates) < n {
+		i := r.Intn(size)
+		if drawn[i] {
+			continue
+		}
+		drawn[i] = true
+		candidates = append(candidates, at(space, i))
+	}
+	return candidates
+}
+
+// at returns the i-th candidate in space.
+func at(space []Dimension, i int) Candidate {
+	c := make(Candidate, len(space))
+	for d := len(space) - 1; d >= 0; d-- {
+		values := space[d].Values
+		c[space[d].Name] = values[i%len(values)]
+		i /= len(values)
+	}
+	return c
+}
@@ -0,0 +1,45 @@
+package search
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var space = []Dimension{
+	{Name: "a", Values: []string{"1", "2"}},
+	{Name: "b", Values: []string{"x", "y", "z"}},
+}
+
+func TestGrid(t *testing.T) {
+	candidates := Grid(space)
+
+	assert.Equal(t, 6, Size(space))
+	assert.Len(t, candidates, 6)
+	assert.Equal(t, Candidate{"a": "1", "b": "x"}, candidates[0])
+	assert.Equal(t, Candidate{"a": "1", "b": "y"}, candidates[1])
+	assert.Equal(t, Candidate{"a": "2", "b": "z"}, candidates[5])
+}
+
+func TestGridOfEmptySpace(t *testing.T) {
+	assert.Equal(t, []Candidate{{}}, Grid(nil))
+}
+
+func TestRandomIsDistinct(t *testing.T) {
+	candidates := Random(space, 4, rand.New(rand.NewSource(1)))
+
+	assert.Len(t, candidates, 4)
+	for i := range candidates {
+		for j := range candidates[:i] {
+			assert.NotEqual(t, candidates[j], candidates[i])
+		}
+	}
+}
+
+func TestRandomIsLimitedBySize(t *testing.T) {
+	candidates := Random(space, 10, rand.New(rand.NewSource(1)))
+
+	assert.Len(t, candidates, 6)
+	assert.ElementsMatch(t, Grid(space), candidates)
+}
@@ -0,0 +1,115 @@
+package solar
+
+import (
+	"math"
+	"time"
+)
+
+// Constants of the clear-sky model
+const (
+	// SolarConstant is the extraterrestrial irradiance in W/m².
+	SolarConstant = 1353.0
+	// STCIrradiance is the irradiance under standard test conditions in W/m².
+	// A panel produces its peak-power at this irradiance.
+	STCIrradiance = 1000.0
+	// DefaultAlbedo is the ground reflectance used if none is specified.
+	DefaultAlbedo = 0.2
+)
+
+// Irradiance holds the components of solar irradiance in W/m².
+type Irradiance struct {
+	// Direct is the direct normal irradiance (DNI).
+	Direct float64
+	// Diffuse is the diffuse horizontal irradiance (DHI).
+	Diffuse float64
+	// Global is the global horizontal irradiance (GHI).
+	Global float64
+}
+
+// Plane describes an oriented surface, e.g. a PV-array.
+type Plane struct {
+	// Latitude in degrees (north is positive).
+	Latitude float64
+	// Longitude in degrees (east is positive).
+	Longitude float64
+	// Tilt is the angle between the plane and the horizontal in degrees.
+	Tilt float64
+	// Azimuth is the direction the plane faces, measured clockwise from north
+	// in degrees (i.e. 180 is south).
+	Azimuth float64
+	// Albedo is the ground's reflectance. DefaultAlbedo is used if it is 0.
+	Albedo float64
+}
+
+// ClearSky returns the irradiance under a cloudless sky for the given sun
+// position. It uses Meinel's air-mass based model for the direct component
+// and approximates the diffuse component as a tenth of the direct one.
+func ClearSky(p Position) Irradiance {
+	if !p.IsUp() {
+		return Irradiance{}
+	}
+	cosz := math.Cos(rad(p.Zenith))
+	dni := SolarConstant * math.Pow(0.7, math.Pow(AirMass(p), 0.678))
+	dhi := 0.1 * dni
+	return Irradiance{
+		Direct:  dni,
+		Diffuse: dhi,
+		Global:  dni*cosz + dhi,
+	}
+}
+
+// AirMass returns the relative optical air mass for the given sun position as
+// described by Kasten and Young. It returns +Inf if the sun is down.
+func AirMass(p Position) float64 {
+	if !p.IsUp() {
+		return math.Inf(1)
+	}
+	return 1 / (math.Cos(rad(p.Zenith)) + 0.50572*math.Pow(96.07995-p.Zenith, -1.6364))
+}
+
+// CloudAttenuation returns the factor by which the global irradiance is
+// reduced for the given cloud cover (0 to 1) as described by Kasten and
+// Czeplak.
+func CloudAttenuation(cloudCover float64) float64 {
+	return 1 - 0.75*math.Pow(clamp(cloudCover, 0, 1), 3.4)
+}
+
+// Incidence returns the angle of incidence (in degrees) of the sun's rays on
+// the plane.
+func (pl Plane) Incidence(p Position) float64 {
+	z, tilt := rad(p.Zenith), rad(pl.Tilt)
+	cos := math.Cos(z)*math.Cos(tilt) + math.Sin(z)*math.Sin(tilt)*math.Cos(rad(p.Azimuth-pl.Azimuth))
+	return deg(math.Acos(clamp(cos, -1, 1)))
+}
+
+// PlaneOfArray transposes the given irradiance onto the plane using the
+// isotropic sky model. The result is the total irradiance in W/m².
+func (pl Plane) PlaneOfArray(p Position, i Irradiance) float64 {
+	if !p.IsUp() {
+		return 0
+	}
+	albedo := pl.Albedo
+	if albedo == 0 {
+		albedo = DefaultAlbedo
+	}
+	tilt := rad(pl.Tilt)
+	beam := i.Direct * math.Max(0, math.Cos(rad(pl.Incidence(p))))
+	sky := i.Diffuse * (1 + math.Cos(tilt)) / 2
+	ground := i.Global * albedo * (1 - math.Cos(tilt)) / 2
+	return beam + sky + ground
+}
+
+// ClearSkyPower returns the power (in the unit of peakPower) a PV-array with
+// the given peak-power would produce on the plane at time t under a cloudless
+// sky.
+func (pl Plane) ClearSkyPower(t time.Time, peakPower float64) float64 {
+	p := PositionAt(t, pl.Latitude, pl.Longitude)
+	return peakPower * pl.PlaneOfArray(p, ClearSky(p)) / STCIrradiance
+}
+
+// Power returns the power (in the unit of peakPower) a PV-array with the given
+// peak-power would produce on the plane at time t, when the sky is covered by
+// clouds to the given fraction (0 to 1).
+func (pl Plane) Power(t time.Time, peakPower, cloudCover float64) float64 {
+	return pl.ClearSkyPower(t, peakPower) * CloudAttenuation(cloudCover)
+}
@@ -0,0 +1,69 @@
+package solar
+
+import (
+	"math"
+	"time"
+)
+
+// Position describes the sun's position in the sky as seen from some point on
+// earth. All angles are in degrees.
+type Position struct {
+	// Zenith is the angle between the sun and the vertical.
+	Zenith float64
+	// Azimuth is the sun's direction measured clockwise from north.
+	Azimuth float64
+}
+
+// Elevation returns the sun's angle above the horizon.
+func (p Position) Elevation() float64 {
+	return 90 - p.Zenith
+}
+
+// IsUp returns true if the sun is above the horizon.
+func (p Position) IsUp() bool {
+	return p.Zenith < 90
+}
+
+// PositionAt calculates the sun's position at time t for the given latitude
+// and longitude (in degrees, north and east are positive). It uses NOAA's
+// general solar position approximation, which is accurate to a few tenths of a
+// degree.
+func PositionAt(t time.Time, latitude, longitude float64) Position {
+	t = t.UTC()
+	hours := float64(t.Hour()) + float64(t.Minute())/60 + float64(t.Second())/3600
+
+	// fractional year in radians
+	g := 2 * math.Pi / 365 * (float64(t.YearDay()-1) + (hours-12)/24)
+
+	// equation of time in minutes
+	eqtime := 229.18 * (0.000075 + 0.001868*math.Cos(g) - 0.032077*math.Sin(g) - 0.014615*math.Cos(2*g) - 0.040849*math.Sin(2*g))
+	// declination in radians
+	decl := 0.006918 - 0.399912*math.Cos(g) + 0.070257*math.Sin(g) - 0.006758*math.Cos(2*g) + 0.000907*math.Sin(2*g) - 0.002697*math.Cos(3*g) + 0.00148*math.Sin(3*g)
+
+	// true solar time in minutes and hour angle in radians
+	tst := hours*60 + eqtime + 4*longitude
+	ha := rad(tst/4 - 180)
+
+	lat := rad(latitude)
+	cosz := math.Sin(lat)*math.Sin(decl) + math.Cos(lat)*math.Cos(decl)*math.Cos(ha)
+	zenith := math.Acos(clamp(cosz, -1, 1))
+
+	azimuth := math.Atan2(math.Sin(ha), math.Cos(ha)*math.Sin(lat)-math.Tan(decl)*math.Cos(lat)) + math.Pi
+
+	return Position{
+		Zenith:  deg(zenith),
+		Azimuth: math.Mod(deg(azimuth)+360, 360),
+	}
+}
+
+func rad(d float64) float64 {
+	return d * math.Pi / 180
+}
+
+func deg(r float64) float64 {
+	return r * 180 / math.Pi
+}
+
+func clamp(v, min, max float64) float64 {
+	return math.Max(min, math.Min(max, v))
+}
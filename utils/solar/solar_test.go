@@ -0,0 +1,38 @@
+package solar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPositionAtNoon(t *testing.T) {
+	// Munich, summer solstice, approximately solar noon
+	p := PositionAt(time.Date(2019, 6, 21, 11, 14, 0, 0, time.UTC), 48.14, 11.58)
+	assert.InDelta(t, 48.14-23.44, p.Zenith, 0.5)
+	assert.InDelta(t, 180, p.Azimuth, 2)
+}
+
+func TestPositionAtNight(t *testing.T) {
+	p := PositionAt(time.Date(2019, 6, 21, 23, 0, 0, 0, time.UTC), 48.14, 11.58)
+	assert.False(t, p.IsUp())
+	assert.Equal(t, Irradiance{}, ClearSky(p))
+}
+
+func TestClearSkyPower(t *testing.T) {
+	south := Plane{Latitude: 48.14, Longitude: 11.58, Tilt: 30, Azimuth: 180}
+	north := Plane{Latitude: 48.14, Longitude: 11.58, Tilt: 30, Azimuth: 0}
+	noon := time.Date(2019, 6, 21, 11, 14, 0, 0, time.UTC)
+
+	p := south.ClearSkyPower(noon, 1000)
+	assert.True(t, p > 700 && p < 1100, p)
+	assert.True(t, north.ClearSkyPower(noon, 1000) < p)
+	assert.Equal(t, 0.0, south.ClearSkyPower(noon.Add(12*time.Hour), 1000))
+}
+
+func TestCloudAttenuation(t *testing.T) {
+	assert.Equal(t, 1.0, CloudAttenuation(0))
+	assert.InDelta(t, 0.25, CloudAttenuation(1), 1e-9)
+	assert.True(t, CloudAttenuation(0.5) > CloudAttenuation(0.8))
+}
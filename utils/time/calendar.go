@@ -0,0 +1,55 @@
+package time
+
+import (
+	"errors"
+	"time"
+)
+
+// Calendar granularities
+const (
+	Hour  = "hour"
+	Day   = "day"
+	Week  = "week"
+	Month = "month"
+)
+
+// ErrUnknownGranularity is returned for granularities other than the ones
+// defined by this package.
+var ErrUnknownGranularity = errors.New("granularity must be one of: " + Hour + ", " + Day + ", " + Week + ", " + Month)
+
+// PeriodStart returns the start of the calendar period of the given
+// granularity t belongs to in t's time zone. Weeks start on Monday.
+func PeriodStart(t time.Time, granularity string) (time.Time, error) {
+	switch granularity {
+	case Hour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location()), nil
+	case Day:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()), nil
+	case Week:
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location()), nil
+	case Month:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()), nil
+	default:
+		return time.Time{}, ErrUnknownGranularity
+	}
+}
+
+// NextPeriod returns the start of the calendar period following the one t
+// belongs to.
+func NextPeriod(t time.Time, granularity string) (time.Time, error) {
+	start, err := PeriodStart(t, granularity)
+	if err != nil {
+		return start, err
+	}
+	switch granularity {
+	case Hour:
+		return start.Add(time.Hour), nil
+	case Day:
+		return time.Date(start.Year(), start.Month(), start.Day()+1, 0, 0, 0, 0, start.Location()), nil
+	case Week:
+		return time.Date(start.Year(), start.Month(), start.Day()+7, 0, 0, 0, 0, start.Location()), nil
+	default:
+		return time.Date(start.Year(), start.Month()+1, 1, 0, 0, 0, 0, start.Location()), nil
+	}
+}
@@ -0,0 +1,28 @@
+package time
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeriodStartHourInOffsetZones(t *testing.T) {
+	for _, name := range []string{"UTC", "Asia/Kolkata", "Australia/Adelaide", "Asia/Kathmandu"} {
+		t.Run(name, func(t *testing.T) {
+			loc, err := time.LoadLocation(name)
+			if !assert.NoError(t, err) {
+				return
+			}
+			at := time.Date(2020, time.March, 4, 13, 47, 12, 5, loc)
+
+			start, err := PeriodStart(at, Hour)
+			assert.NoError(t, err)
+			assert.True(t, time.Date(2020, time.March, 4, 13, 0, 0, 0, loc).Equal(start), start.String())
+
+			next, err := NextPeriod(at, Hour)
+			assert.NoError(t, err)
+			assert.True(t, time.Date(2020, time.March, 4, 14, 0, 0, 0, loc).Equal(next), next.String())
+		})
+	}
+}